@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Suffix format of rotated -access-log files, e.g. access.log.20240102-150405.000
+const accessLogRotateFormat = "20060102-150405.000"
+
+// One line of -access-log, written for every request to a webhook route
+type accessLogEntry struct {
+	Time       time.Time `json:"time"`
+	RemoteAddr string    `json:"remote_addr"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	// Comma-separated for batches with mixed events; empty when the
+	// request was rejected before its event was known
+	Event     string  `json:"event"`
+	Status    int     `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	RequestID string  `json:"request_id,omitempty"`
+}
+
+type accessRecordKey struct{}
+
+// Filled in by the handlers while the request is served
+type accessRecord struct {
+	events []string
+}
+
+// Record the event of a webhook in the request's access log entry, if
+// access logging is on
+func noteAccessEvent(r *http.Request, event string) {
+	rec, ok := r.Context().Value(accessRecordKey{}).(*accessRecord)
+	if ok && !slices.Contains(rec.events, event) {
+		rec.events = append(rec.events, event)
+	}
+}
+
+// Write an -access-log line for each request, once the handler is done.
+// Sits inside withRequestID so the line carries the request ID.
+func (s *server) withAccessLog(next http.HandlerFunc) http.HandlerFunc {
+	if s.accessLog == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &accessRecord{}
+		sw := &statusWriter{ResponseWriter: w}
+		next(sw, r.WithContext(context.WithValue(r.Context(), accessRecordKey{}, rec)))
+
+		status := sw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		line, err := json.Marshal(accessLogEntry{
+			Time:       start.UTC(),
+			RemoteAddr: s.clientIP(r),
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Event:      strings.Join(rec.events, ","),
+			Status:     status,
+			LatencyMS:  float64(time.Since(start).Microseconds()) / 1000,
+			RequestID:  requestIDFrom(r),
+		})
+		if err != nil {
+			slog.Error("failed to encode access log entry", "error", err)
+			return
+		}
+		if _, err := s.accessLog.Write(append(line, '\n')); err != nil {
+			slog.Error("failed to write access log", "path", s.cfg.accessLogPath, "error", err)
+		}
+	}
+}
+
+// Remembers the status a handler answered with
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// For http.ResponseController
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Append-only file that is renamed aside with a timestamp suffix and
+// reopened once a write would take it past maxBytes (0 never rotates).
+// Whole lines are written, so no entry is split across files.
+type rotatingFile struct {
+	mu       sync.Mutex
+	path     string
+	maxBytes int64
+	f        *os.File
+	size     int64
+}
+
+func openRotatingFile(path string, maxBytes int64) (*rotatingFile, error) {
+	rf := &rotatingFile{path: path, maxBytes: maxBytes}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *rotatingFile) open() error {
+	f, err := os.OpenFile(rf.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	rf.f, rf.size = f, info.Size()
+	return nil
+}
+
+func (rf *rotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.maxBytes > 0 && rf.size > 0 && rf.size+int64(len(p)) > rf.maxBytes {
+		if err := rf.rotateLocked(); err != nil {
+			// Keep appending to the current file rather than lose entries
+			slog.Error("failed to rotate access log", "path", rf.path, "error", err)
+		}
+	}
+	if rf.f == nil {
+		// A reopen after rotation failed; try again
+		if err := rf.open(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := rf.f.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+// Callers must hold rf.mu
+func (rf *rotatingFile) rotateLocked() error {
+	rotated := rf.path + "." + time.Now().UTC().Format(accessLogRotateFormat)
+	if err := os.Rename(rf.path, rotated); err != nil {
+		return err
+	}
+	if err := rf.f.Close(); err != nil {
+		slog.Warn("failed to close rotated access log", "path", rotated, "error", err)
+	}
+	rf.f, rf.size = nil, 0
+	if err := rf.open(); err != nil {
+		return err
+	}
+	slog.Info("access log rotated", "path", rf.path, "rotated_to", rotated)
+	return nil
+}
+
+func (rf *rotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.f == nil {
+		return nil
+	}
+	err := rf.f.Close()
+	rf.f = nil
+	return err
+}
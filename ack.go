@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Answer an accepted webhook with -ack-status and -ack-body, or with
+// response as JSON when no custom body is configured. The webhook is
+// already stored; any -response-delay happens first. JSON responses carry
+// the request ID.
+func (s *server) writeAck(w http.ResponseWriter, r *http.Request, response map[string]interface{}) {
+	s.delayResponse(r)
+	if id := requestIDFrom(r); id != "" {
+		response["request_id"] = id
+	}
+
+	switch {
+	case s.cfg.ackBody != "":
+		w.Header().Set("Content-Type", s.cfg.ackContentType)
+		w.WriteHeader(s.cfg.ackStatus)
+		io.WriteString(w, s.cfg.ackBody)
+	case !bodyAllowedForStatus(s.cfg.ackStatus):
+		w.WriteHeader(s.cfg.ackStatus)
+	default:
+		w.Header().Set("Content-Type", s.cfg.ackContentType)
+		w.WriteHeader(s.cfg.ackStatus)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+const ackJSONContentType = "application/json"
+
+// Settle the ack's Content-Type and check it agrees with the body. An
+// explicit -ack-content-type must be a valid media type, JSON when the ack
+// is the default JSON body or a JSON -ack-body is declared as JSON. Left
+// at its default, a custom body that isn't JSON is sent as text/plain.
+func resolveAckContentType(contentType string, explicit bool, body string, status int) (string, error) {
+	if !explicit {
+		if body != "" && !json.Valid([]byte(body)) {
+			return "text/plain; charset=utf-8", nil
+		}
+		return contentType, nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", fmt.Errorf("invalid -ack-content-type %q: %w", contentType, err)
+	}
+	if !bodyAllowedForStatus(status) {
+		return "", fmt.Errorf("-ack-content-type can't be used with ack status %d, which has no body", status)
+	}
+	isJSON := mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+	switch {
+	case body == "" && !isJSON:
+		return "", fmt.Errorf("-ack-content-type %q needs an -ack-body; the default ack body is JSON", contentType)
+	case body != "" && isJSON && !json.Valid([]byte(body)):
+		return "", fmt.Errorf("-ack-content-type is %q but -ack-body is not valid JSON", contentType)
+	}
+	return contentType, nil
+}
+
+func bodyAllowedForStatus(status int) bool {
+	return status != http.StatusNoContent && status != http.StatusNotModified
+}
+
+// Resolve an -ack-body value: a literal string, or the contents of a file
+// when it starts with @
+func loadAckBody(v string) (string, error) {
+	path, ok := strings.CutPrefix(v, "@")
+	if !ok {
+		return v, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading ack body: %w", err)
+	}
+	return string(data), nil
+}
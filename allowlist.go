@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+)
+
+// Parse -allow-cidr entries. A bare address is taken as a single-host
+// range.
+func parsePrefixes(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(entry)
+			if addrErr != nil {
+				return nil, fmt.Errorf("invalid CIDR %q: %w", entry, err)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// Whether ip (as returned by clientIP) is in any of prefixes. IPv4-mapped
+// IPv6 addresses match IPv4 ranges.
+func inPrefixes(ip string, prefixes []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Refuse webhooks from clients outside -allow-cidr with 403
+func (s *server) allowSource(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(s.cfg.allowCIDRs) > 0 && !inPrefixes(s.clientIP(r), s.cfg.allowCIDRs) {
+			s.reject(w, r, rejectSource, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
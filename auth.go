@@ -0,0 +1,75 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+)
+
+const (
+	readAuthRealm    = "webhook-receiver"
+	adminTokenHeader = "X-Admin-Token"
+)
+
+// Require HTTP Basic Auth with -read-user/-read-pass on the read endpoints.
+// Does nothing when no credentials are configured.
+func (s *server) readAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.cfg.readUser == "" {
+			next(w, r)
+			return
+		}
+
+		user, pass, ok := r.BasicAuth()
+		if !ok || !credentialsMatch(user, pass, s.cfg.readUser, s.cfg.readPass) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="`+readAuthRealm+`", charset="UTF-8"`)
+			writeError(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// Compare both fields in constant time, without short-circuiting on the
+// user name
+func credentialsMatch(user, pass, wantUser, wantPass string) bool {
+	userOK := secureCompare(user, wantUser)
+	passOK := secureCompare(pass, wantPass)
+	return userOK && passOK
+}
+
+// Constant-time string comparison. Hashing first keeps the comparison from
+// leaking the expected length.
+func secureCompare(got, want string) bool {
+	gotHash, wantHash := sha256.Sum256([]byte(got)), sha256.Sum256([]byte(want))
+	return subtle.ConstantTimeCompare(gotHash[:], wantHash[:]) == 1
+}
+
+// Require the -admin-token in X-Admin-Token on destructive endpoints. The
+// read credentials apply too: these endpoints expose or replace everything
+// the read endpoints do, so they mustn't be open when only -read-user is
+// set.
+func (s *server) adminAuth(next http.HandlerFunc) http.HandlerFunc {
+	return s.readAuth(func(w http.ResponseWriter, r *http.Request) {
+		if s.requireAdmin(w, r) {
+			next(w, r)
+		}
+	})
+}
+
+// Check the admin token, answering 403 and returning false when it's
+// missing or wrong
+func (s *server) requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if s.isAdmin(r) {
+		return true
+	}
+	slog.Warn("admin token rejected", "method", r.Method, "path", r.URL.Path, "remote_addr", s.clientIP(r))
+	writeError(w, "Forbidden", http.StatusForbidden)
+	return false
+}
+
+func (s *server) isAdmin(r *http.Request) bool {
+	return s.cfg.adminToken == "" || secureCompare(r.Header.Get(adminTokenHeader), s.cfg.adminToken)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/http"
+	"testing"
+)
+
+func basicAuth(user, pass string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass))
+}
+
+// Read credentials without an admin token must still protect the admin
+// endpoints, which hand out or replace the whole store
+func TestAdminEndpointsRequireReadAuth(t *testing.T) {
+	srv := newTestServer(t, "-read-user", "u", "-read-pass", "p")
+	mustPost(t, srv, `{"secret":"payload"}`)
+
+	for _, tt := range []struct{ method, path string }{
+		{http.MethodGet, "/admin/snapshot"},
+		{http.MethodPost, "/admin/restore"},
+		{http.MethodPost, "/admin/pause"},
+		{http.MethodPost, "/admin/resume"},
+		{http.MethodPut, "/config/max-size"},
+		{http.MethodPost, "/webhooks/clear"},
+		{http.MethodPost, "/webhooks/import"},
+	} {
+		rec := serve(srv, tt.method, tt.path, "", nil)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s %s without credentials: status %d, want 401", tt.method, tt.path, rec.Code)
+		}
+	}
+	if srv.store.Len() != 1 {
+		t.Error("an unauthenticated request changed the store")
+	}
+
+	if rec := serve(srv, http.MethodGet, "/admin/snapshot", "", http.Header{"Authorization": {basicAuth("u", "p")}}); rec.Code != http.StatusOK {
+		t.Errorf("GET /admin/snapshot with credentials: status %d, want 200", rec.Code)
+	}
+}
+
+func TestAdminTokenWithReadAuth(t *testing.T) {
+	srv := newTestServer(t, "-read-user", "u", "-read-pass", "p", "-admin-token", "t0ken")
+	basic := basicAuth("u", "p")
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   int
+	}{
+		{"token without credentials", http.Header{adminTokenHeader: {"t0ken"}}, http.StatusUnauthorized},
+		{"credentials without token", http.Header{"Authorization": {basic}}, http.StatusForbidden},
+		{"both", http.Header{"Authorization": {basic}, adminTokenHeader: {"t0ken"}}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if rec := serve(srv, http.MethodGet, "/admin/snapshot", "", tt.header); rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+}
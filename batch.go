@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// Store each element of a top-level JSON array as its own webhook
+// (-expand-arrays). The batch is validated up front so a validation failure
+// rejects it whole, but a storage error partway through leaves the elements
+// before it stored; see rejectPartialBatch.
+func (s *server) receiveBatch(w http.ResponseWriter, r *http.Request, bucket string, body []byte, elements []interface{}, verified bool) {
+	if s.schema != nil {
+		if failures := validateElements(s.schema, elements); failures != nil {
+			s.rejectSchema(w, r, failures)
+			return
+		}
+	}
+
+	store, ok := s.bucketStore(w, r, bucket)
+	if !ok {
+		return
+	}
+	key := s.idempotencyKey(r)
+	ids := make([]int, 0, len(elements))
+	duplicates := 0
+	var events []string
+	for i, element := range elements {
+		raw, err := json.Marshal(element)
+		if err != nil {
+			// Decoded from JSON, so this can't happen in practice
+			slog.Error("failed to re-encode array element", "index", i, "error", err)
+			continue
+		}
+
+		elementKey := key
+		if key != "" {
+			elementKey = fmt.Sprintf("%s#%d", key, i)
+		}
+		stored, duplicate, err := addWebhook(store, s.newWebhook(r, raw, element, verified), elementKey)
+		if err != nil {
+			if len(ids) == 0 {
+				s.rejectStorageError(w, r, bucket, err)
+			} else {
+				s.rejectPartialBatch(w, r, bucket, err, ids)
+			}
+			return
+		}
+		ids = append(ids, stored.ID)
+		noteAccessEvent(r, stored.Event)
+		if duplicate {
+			duplicates++
+			continue
+		}
+		s.metrics.countReceived(bucket, stored.Event)
+		events = append(events, stored.Event)
+		slog.Info("webhook stored",
+			"webhook_id", stored.ID,
+			"bucket", bucket,
+			"event", stored.Event,
+			"delivery_id", stored.DeliveryID,
+			"batch_index", i,
+			"remote_addr", stored.RemoteAddr,
+			"request_id", stored.RequestID,
+			"status", http.StatusOK,
+			summaryAttr(summarize(stored.Payload, s.cfg.summaryFields), s.cfg.summaryFields),
+		)
+	}
+
+	// Downstream receivers get the batch exactly as it was sent, routed by
+	// the events of the newly stored elements
+	if s.forwarder != nil && len(events) > 0 {
+		s.forwarder.Forward(body, r.Header.Get("Content-Type"), events...)
+	}
+
+	s.writeAck(w, r, map[string]interface{}{
+		"message":    "Webhooks received and stored successfully",
+		"count":      len(ids),
+		"ids":        ids,
+		"duplicates": duplicates,
+	})
+}
+
+// 503 body for a batch whose storage failed partway through
+type partialBatchResponse struct {
+	errorResponse
+	Count int   `json:"count"`
+	IDs   []int `json:"ids"`
+}
+
+// Like rejectStorageError, but for a batch some of whose elements were
+// stored before the failure. Their IDs are returned so the sender knows
+// what landed; with -dedup-header a redelivery skips them.
+func (s *server) rejectPartialBatch(w http.ResponseWriter, r *http.Request, bucket string, err error, ids []int) {
+	slog.Error("failed to persist webhook", "bucket", bucket, "request_id", requestIDFrom(r), "stored_before_failure", len(ids), "error", err)
+	s.metrics.rejected.WithLabelValues(rejectStorage).Inc()
+
+	status := http.StatusServiceUnavailable
+	h := w.Header()
+	h.Set("Retry-After", strconv.Itoa(storageRetryAfter))
+	h.Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(partialBatchResponse{
+		errorResponse: errorResponse{Error: "Failed to store the whole batch, retry later", Code: status},
+		Count:         len(ids),
+		IDs:           ids,
+	})
+}
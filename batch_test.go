@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestExpandArrays(t *testing.T) {
+	srv := newTestServer(t, "-expand-arrays", "-max", "5")
+	mustPost(t, srv, `{"before":true}`)
+
+	rec := postJSON(srv, "/webhook", `[{"n":1},{"n":2},{"n":3}]`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+	}
+	var ack ackResponse
+	decodeResponse(t, rec, &ack)
+	if ack.Count != 3 || !slices.Equal(ack.IDs, []int{2, 3, 4}) {
+		t.Errorf("ack count %d ids %v, want 3 ids [2 3 4]", ack.Count, ack.IDs)
+	}
+	if got := listedIDs(t, srv); !slices.Equal(got, []int{4, 3, 2, 1}) {
+		t.Errorf("stored ids = %v, want [4 3 2 1]", got)
+	}
+
+	// Eviction still applies element by element
+	mustPost(t, srv, `[{"n":4},{"n":5},{"n":6}]`)
+	if got := listedIDs(t, srv); !slices.Equal(got, []int{7, 6, 5, 4, 3}) {
+		t.Errorf("ids after overflowing = %v, want [7 6 5 4 3]", got)
+	}
+}
+
+func TestExpandArraysSingleObject(t *testing.T) {
+	srv := newTestServer(t, "-expand-arrays")
+	rec := postJSON(srv, "/webhook", `{"n":1}`)
+	var ack ackResponse
+	decodeResponse(t, rec, &ack)
+	if rec.Code != http.StatusOK || ack.ID != 1 || ack.IDs != nil {
+		t.Errorf("status %d, ack %+v; want the single-webhook ack", rec.Code, ack)
+	}
+}
+
+// In-memory store whose durable writes start failing after a number of
+// successes
+type failingStore struct {
+	*WebhookStore
+	okWrites int
+}
+
+var errDiskFull = errors.New("disk full")
+
+func (fs *failingStore) AddDurable(webhook StoredWebhook, key string) (StoredWebhook, bool, error) {
+	if fs.okWrites == 0 {
+		return StoredWebhook{}, false, errDiskFull
+	}
+	fs.okWrites--
+	return fs.WebhookStore.AddDurable(webhook, key)
+}
+
+func TestExpandArraysPartialFailure(t *testing.T) {
+	srv := newTestServer(t, "-expand-arrays", "-max", "5")
+	failing := &failingStore{WebhookStore: NewWebhookStore(5, 0, modeLIFO), okWrites: 2}
+	srv.buckets = newBucketRegistry(failing, nil, 0)
+
+	rec := postJSON(srv, "/webhook", `[{"n":1},{"n":2},{"n":3}]`)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status %d, want 503; body %s", rec.Code, rec.Body)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("no Retry-After header")
+	}
+	var resp partialBatchResponse
+	decodeResponse(t, rec, &resp)
+	if resp.Count != 2 || !slices.Equal(resp.IDs, []int{1, 2}) || resp.Error == "" {
+		t.Errorf("response %+v, want an error listing ids [1 2]", resp)
+	}
+	if got := failing.Len(); got != 2 {
+		t.Errorf("%d webhooks stored, want the 2 before the failure", got)
+	}
+
+	// Nothing stored at all is a plain storage error
+	failing.okWrites = 0
+	rec = postJSON(srv, "/webhook", `[{"n":4}]`)
+	var plain partialBatchResponse
+	decodeResponse(t, rec, &plain)
+	if rec.Code != http.StatusServiceUnavailable || plain.IDs != nil {
+		t.Errorf("status %d, response %+v; want 503 without ids", rec.Code, plain)
+	}
+}
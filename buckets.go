@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Bucket that /webhook and /webhooks map to
+const defaultBucket = "default"
+
+// Named stores, created lazily on first POST. Each bucket has its own
+// mutex, max size and ID sequence.
+type bucketRegistry struct {
+	mu       sync.Mutex
+	buckets  map[string]Store
+	newStore func(name string) Store
+
+	// Most buckets Get will create, counting the default; 0 for no limit
+	maxBuckets int
+}
+
+func newBucketRegistry(defaultStore Store, newStore func(name string) Store, maxBuckets int) *bucketRegistry {
+	return &bucketRegistry{
+		buckets:    map[string]Store{defaultBucket: defaultStore},
+		newStore:   newStore,
+		maxBuckets: maxBuckets,
+	}
+}
+
+// Return the named bucket, creating it if it doesn't exist yet. False when
+// it doesn't and -max-buckets already exist.
+func (br *bucketRegistry) Get(name string) (Store, bool) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+
+	store, ok := br.buckets[name]
+	if !ok {
+		if br.maxBuckets > 0 && len(br.buckets) >= br.maxBuckets {
+			return nil, false
+		}
+		store = br.newStore(name)
+		br.buckets[name] = store
+	}
+	return store, true
+}
+
+// Register buckets that already have stored webhooks. The limit doesn't
+// apply, so no stored data becomes unreachable after a restart.
+func (br *bucketRegistry) Preload(names ...string) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+
+	for _, name := range names {
+		if _, ok := br.buckets[name]; !ok {
+			br.buckets[name] = br.newStore(name)
+		}
+	}
+}
+
+// Return the named bucket only if it already exists
+func (br *bucketRegistry) Lookup(name string) (Store, bool) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+
+	store, ok := br.buckets[name]
+	return store, ok
+}
+
+// Return all bucket names, sorted
+func (br *bucketRegistry) Names() []string {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+
+	names := make([]string, 0, len(br.buckets))
+	for name := range br.buckets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Names that would collide with fixed routes under /webhook/ and /webhooks/
+var reservedBucketNames = []string{"validate", "clear", "stream", "search", "export", "export.csv", "stats", "import", "latest", "by-day"}
+
+// Bucket names are limited to URL-safe characters and must not be purely
+// numeric, so /webhooks/{name} can't be confused with /webhooks/{id}
+func validBucketName(name string) bool {
+	if name == "" || len(name) > 64 || slices.Contains(reservedBucketNames, name) {
+		return false
+	}
+	numeric := true
+	for _, c := range name {
+		switch {
+		case c >= '0' && c <= '9':
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '-', c == '_', c == '.':
+			numeric = false
+		default:
+			return false
+		}
+	}
+	return !numeric
+}
+
+// POST /webhook/{name}
+// Get the bucket to store a webhook in, rejecting the request with 507 when
+// it would be a new bucket beyond -max-buckets
+func (s *server) bucketStore(w http.ResponseWriter, r *http.Request, bucket string) (Store, bool) {
+	store, ok := s.buckets.Get(bucket)
+	if !ok {
+		slog.Warn("bucket limit reached", "bucket", bucket, "max_buckets", s.cfg.maxBuckets)
+		s.reject(w, r, rejectBucketLimit, fmt.Sprintf("Bucket limit of %d reached", s.cfg.maxBuckets), http.StatusInsufficientStorage)
+	}
+	return store, ok
+}
+
+func (s *server) bucketWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/webhook/")
+	if !validBucketName(name) {
+		writeError(w, "Invalid bucket name", http.StatusBadRequest)
+		return
+	}
+
+	s.receiveWebhook(w, r, name)
+}
+
+func (s *server) listBucketsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	names := s.buckets.Names()
+	buckets := make([]map[string]interface{}, 0, len(names))
+	for _, name := range names {
+		store, _ := s.buckets.Lookup(name)
+		bucket := map[string]interface{}{
+			"name":  name,
+			"count": store.Len(),
+		}
+		if b, ok := store.(boundedStore); ok {
+			bucket["max_size"] = b.MaxSize()
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	writeJSON(w, r, map[string]interface{}{
+		"count":   len(buckets),
+		"buckets": buckets,
+	})
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Webhooks grouped by the calendar day they were received on, newest day
+// first, as served by /webhooks/by-day
+type webhookDays struct {
+	days     []string
+	webhooks map[string][]StoredWebhook
+}
+
+// Group webhooks, given newest first, by their Received day in the -tz
+// location. Days without webhooks don't appear.
+func (s *server) groupByDay(webhooks []StoredWebhook) webhookDays {
+	groups := webhookDays{webhooks: make(map[string][]StoredWebhook)}
+	for _, webhook := range webhooks {
+		day := webhook.Received.In(s.cfg.location).Format("2006-01-02")
+		if _, ok := groups.webhooks[day]; !ok {
+			groups.days = append(groups.days, day)
+		}
+		groups.webhooks[day] = append(groups.webhooks[day], webhook)
+	}
+	// Already newest first unless Received went backwards with the clock
+	slices.SortFunc(groups.days, func(a, b string) int {
+		return strings.Compare(b, a)
+	})
+	return groups
+}
+
+// An object keyed by day in newest-first order, which a Go map can't keep
+func (d webhookDays) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, day := range d.days {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(day)
+		buf.Write(key)
+		buf.WriteByte(':')
+		list, err := json.Marshal(d.webhooks[day])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(list)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// GET /webhooks/by-day, taking the same filters as /webhooks
+func (s *server) webhooksByDayHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	webhooks, err := filterList(s.store.GetAll(), r.URL.Query(), s.now())
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if s.cfg.mode == modeFIFO {
+		slices.Reverse(webhooks)
+	}
+
+	writeJSON(w, r, map[string]interface{}{
+		"timezone": s.cfg.location.String(),
+		"count":    len(webhooks),
+		"days":     s.groupByDay(webhooks),
+	})
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+	// America/New_York must load on machines without zoneinfo
+	_ "time/tzdata"
+)
+
+func TestWebhooksByDay(t *testing.T) {
+	srv := newTestServer(t, "-tz", "America/New_York")
+	clock := &fakeClock{}
+	srv.store.(*WebhookStore).now = clock.Now
+
+	// 22:30 on the 9th, noon and 21:00 on the 10th, New York time
+	for _, received := range []string{"2024-03-10T03:30:00Z", "2024-03-10T16:00:00Z", "2024-03-11T01:00:00Z"} {
+		clock.now, _ = time.Parse(time.RFC3339, received)
+		mustPost(t, srv, `{}`)
+	}
+
+	rec := serve(srv, http.MethodGet, "/webhooks/by-day", "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+	}
+	var resp struct {
+		Timezone string          `json:"timezone"`
+		Count    int             `json:"count"`
+		Days     json.RawMessage `json:"days"`
+	}
+	decodeResponse(t, rec, &resp)
+	if resp.Timezone != "America/New_York" || resp.Count != 3 {
+		t.Errorf("timezone %q count %d, want America/New_York and 3", resp.Timezone, resp.Count)
+	}
+
+	// Decode day by day to check the key order as well as the grouping
+	dec := json.NewDecoder(bytes.NewReader(resp.Days))
+	dec.Token()
+	want := []struct {
+		day string
+		ids []int
+	}{
+		{"2024-03-10", []int{3, 2}},
+		{"2024-03-09", []int{1}},
+	}
+	for _, w := range want {
+		key, err := dec.Token()
+		if err != nil || key != w.day {
+			t.Fatalf("next day %v (%v), want %s", key, err, w.day)
+		}
+		var webhooks []StoredWebhook
+		if err := dec.Decode(&webhooks); err != nil {
+			t.Fatal(err)
+		}
+		if len(webhooks) != len(w.ids) {
+			t.Fatalf("%s has %d webhooks, want %d", w.day, len(webhooks), len(w.ids))
+		}
+		for i, webhook := range webhooks {
+			if webhook.ID != w.ids[i] {
+				t.Errorf("%s[%d] is webhook %d, want %d", w.day, i, webhook.ID, w.ids[i])
+			}
+		}
+	}
+	if dec.More() {
+		t.Error("unexpected extra days")
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Longest delay a ?delay= override may ask for
+const maxDelayOverride = time.Minute
+
+// Sleep for -response-delay, or for ?delay= when -debug is on, returning
+// early if the client goes away
+func (s *server) delayResponse(r *http.Request) {
+	delay := s.cfg.responseDelay
+	if v := r.URL.Query().Get("delay"); v != "" && s.cfg.debug {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			slog.Debug("ignoring invalid delay override", "delay", v)
+		} else {
+			delay = min(d, s.delayOverrideLimit())
+		}
+	}
+	if delay <= 0 {
+		return
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-r.Context().Done():
+	}
+}
+
+// Cap on ?delay=: maxDelayOverride, and short enough to leave a tenth of
+// -handler-timeout for the response, since a webhook answered with the
+// timeout's 503 is stored anyway and the sender would retry it
+func (s *server) delayOverrideLimit() time.Duration {
+	if s.cfg.handlerTimeout <= 0 {
+		return maxDelayOverride
+	}
+	return min(maxDelayOverride, s.cfg.handlerTimeout-s.cfg.handlerTimeout/10)
+}
+
+// Decides which webhooks get an injected failure for -fail-rate
+type failureInjector struct {
+	mu   sync.Mutex
+	rate float64
+	rng  *rand.Rand
+}
+
+// A zero seed picks a random one
+func newFailureInjector(rate float64, seed uint64) *failureInjector {
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	return &failureInjector{
+		rate: rate,
+		rng:  rand.New(rand.NewPCG(seed, seed)),
+	}
+}
+
+func (fi *failureInjector) Fail() bool {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+
+	return fi.rng.Float64() < fi.rate
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestResponseDelayMustBeShorterThanHandlerTimeout(t *testing.T) {
+	for _, args := range [][]string{
+		{"-handler-timeout", "100ms", "-response-delay", "300ms"},
+		{"-handler-timeout", "100ms", "-response-delay", "100ms"},
+	} {
+		if _, err := loadConfig(args); err == nil {
+			t.Errorf("loadConfig(%q) accepted a delay the handler timeout cuts off", args)
+		}
+	}
+	if _, err := loadConfig([]string{"-handler-timeout", "0", "-response-delay", "300ms"}); err != nil {
+		t.Errorf("delay without a handler timeout: %v", err)
+	}
+}
+
+// A ?delay= override past -handler-timeout would store the webhook but
+// answer 503, prompting a duplicate retry
+func TestDelayOverrideCappedBelowHandlerTimeout(t *testing.T) {
+	srv := newTestServer(t, "-debug", "-handler-timeout", "100ms")
+
+	rec := postJSON(srv, "/webhook?delay=1s", `{"n":1}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200; body %s", rec.Code, rec.Body)
+	}
+	if got := srv.delayOverrideLimit(); got != 90*time.Millisecond {
+		t.Errorf("delay cap %s, want 90ms", got)
+	}
+}
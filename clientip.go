@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Work out the sending client's IP. Every IP-based feature (rate limiting,
+// -allow-cidr, stored remote_addr, logs) goes through here so they agree.
+//
+// Precedence:
+//  1. The connection's peer address is the answer unless that peer is in
+//     -trusted-proxies. X-Forwarded-For from anyone else is ignored, since
+//     any client can set it.
+//  2. From a trusted peer, X-Forwarded-For is read right to left, skipping
+//     further trusted proxies. The first address that isn't one is the
+//     client; entries to its left were supplied by the client and could
+//     be forged.
+//  3. If every entry is a trusted proxy, or the header is missing or
+//     malformed, the left-most valid entry, or failing that the peer, is
+//     used.
+func (s *server) clientIP(r *http.Request) string {
+	peer, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		peer = r.RemoteAddr
+	}
+	if len(s.cfg.trustedProxies) == 0 || !inPrefixes(peer, s.cfg.trustedProxies) {
+		return peer
+	}
+
+	var hops []string
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(value, ",") {
+			if hop = strings.TrimSpace(hop); net.ParseIP(hop) != nil {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		if !inPrefixes(hops[i], s.cfg.trustedProxies) {
+			return hops[i]
+		}
+	}
+	if len(hops) > 0 {
+		return hops[0]
+	}
+	return peer
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// Counts webhook requests being processed and, with a positive limit,
+// turns away those over it
+type concurrencyLimiter struct {
+	limit    int64
+	inFlight atomic.Int64
+}
+
+func newConcurrencyLimiter(limit int) *concurrencyLimiter {
+	return &concurrencyLimiter{limit: int64(limit)}
+}
+
+// Take a slot, reporting false when all are in use. Release must follow a
+// successful Acquire.
+func (l *concurrencyLimiter) Acquire() bool {
+	if n := l.inFlight.Add(1); l.limit > 0 && n > l.limit {
+		l.inFlight.Add(-1)
+		return false
+	}
+	return true
+}
+
+func (l *concurrencyLimiter) Release() {
+	l.inFlight.Add(-1)
+}
+
+func (l *concurrencyLimiter) InFlight() int {
+	return int(l.inFlight.Load())
+}
+
+// Answer 503 once -max-concurrent webhook requests are already being
+// processed, bounding the memory spent decoding payloads
+func (s *server) limitConcurrent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.concurrency.Acquire() {
+			w.Header().Set("Retry-After", "1")
+			s.reject(w, r, rejectOverloaded, "Too many concurrent requests", http.StatusServiceUnavailable)
+			return
+		}
+		defer s.concurrency.Release()
+		next(w, r)
+	}
+}
@@ -0,0 +1,550 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+type config struct {
+	addr     string
+	basePath string
+	maxSize  int
+	maxBytes int64
+	mode     retentionMode
+
+	// Per-bucket overrides of maxSize from -bucket-config
+	bucketSizes map[string]int
+	maxBuckets  int
+
+	tlsCert         string
+	tlsKey          string
+	tlsRedirectAddr string
+	tlsClientCA     string
+
+	persistPath     string
+	persistQueue    int
+	persistOverflow overflowPolicy
+	dbPath          string
+	secret          string
+	schemaPath      string
+
+	preserveIDsOnClear bool
+
+	stripeSecret    string
+	stripeTolerance time.Duration
+	stripeBuckets   []string
+
+	standardSecret    string
+	standardTolerance time.Duration
+	standardBuckets   []string
+
+	// HMAC secrets per bucket from -bucket-secret, overriding -secret
+	bucketSecrets map[string][]string
+
+	shutdownTimeout time.Duration
+	readTimeout     time.Duration
+	writeTimeout    time.Duration
+	idleTimeout     time.Duration
+	handlerTimeout  time.Duration
+	maxBody         int64
+	maxImportBody   int64
+	ttl             time.Duration
+
+	rateLimit float64
+	rateBurst int
+
+	maxConcurrent int
+
+	forwardTargets []string
+	// Targets per event from -forward-map; forwardTargets is the default
+	forwardMap     map[string][]string
+	forwardTimeout time.Duration
+	forwardRetries int
+
+	forwardConcurrency int
+	forwardOverflow    overflowPolicy
+
+	// Dead-letter URL for webhooks evicted by the caps or -ttl
+	evictURL string
+
+	redactHeaders []string
+	redactKeys    []string
+	summaryFields []string
+	dedupHeader   string
+	dedupWindow   time.Duration
+	labelHeader   string
+	expandArrays  bool
+	maxBatch      int
+	ackStatus     int
+	ackBody       string
+	// Content-Type of the ack; see resolveAckContentType
+	ackContentType string
+	methods        []string
+	corsOrigins    []string
+
+	requestIDHeader   string
+	transformTemplate *template.Template
+
+	allowCIDRs []netip.Prefix
+	// Peers whose X-Forwarded-For is believed; see clientIP
+	trustedProxies []netip.Prefix
+
+	replayAllowHosts []string
+
+	readUser string
+	readPass string
+
+	adminToken string
+
+	responseDelay time.Duration
+	debug         bool
+	failRate      float64
+	failSeed      uint64
+
+	rejectRules  []rejectRule
+	rejectStatus int
+
+	// Events answered with a redirect to the URL, for handshake flows
+	redirectEvents map[string]string
+	redirectStatus int
+
+	requireTimestamp bool
+	// Furthest a required timestamp may be from server time; 0 for any
+	timestampSkew time.Duration
+
+	// Timezone for calendar days in /webhooks/by-day, from -tz
+	location *time.Location
+
+	// Distinct events and buckets labelled in webhooks_received_total
+	metricsLabelLimit int
+
+	// JSON line per webhook request, rotated past accessLogMaxMB (0 never)
+	accessLogPath  string
+	accessLogMaxMB int64
+
+	logLevel  string
+	logFormat string
+}
+
+// Parse command-line flags, falling back to environment variables and then
+// built-in defaults when a flag isn't given
+func loadConfig(args []string) (config, error) {
+	var cfg config
+
+	defaultMax := 5
+	if v := os.Getenv("WEBHOOK_MAX_SIZE"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid WEBHOOK_MAX_SIZE %q: %v", v, err)
+		}
+		defaultMax = n
+	}
+
+	fs := flag.NewFlagSet("webhook-receiver", flag.ContinueOnError)
+	fs.StringVar(&cfg.addr, "addr", envOrDefault("WEBHOOK_ADDR", ":8080"), "listen address (env WEBHOOK_ADDR)")
+	fs.StringVar(&cfg.tlsCert, "tls-cert", "", "TLS certificate file; serves HTTPS when set together with -tls-key")
+	fs.StringVar(&cfg.tlsKey, "tls-key", "", "TLS private key file")
+	fs.StringVar(&cfg.tlsClientCA, "tls-client-ca", "", "optional CA bundle for verifying client certificates; clients presenting one have its CN recorded")
+	fs.StringVar(&cfg.tlsRedirectAddr, "tls-redirect-addr", "", "optional plain HTTP listen address that redirects to HTTPS, e.g. :80")
+	basePath := fs.String("base-path", "", "path prefix for every route, e.g. /hooks when mounted behind a proxy")
+	fs.IntVar(&cfg.maxSize, "max", defaultMax, "maximum number of stored webhooks (env WEBHOOK_MAX_SIZE)")
+	fs.IntVar(&cfg.maxBuckets, "max-buckets", 1000, "maximum number of buckets, including the default; POSTs that would create another get 507 (0 for unlimited)")
+	bucketConfig := fs.String("bucket-config", "", "comma-separated name=size pairs overriding -max for individual buckets, e.g. github=100,test=2")
+	fs.Int64Var(&cfg.maxBytes, "max-bytes", 0, "maximum total serialized size of stored webhooks per bucket, in addition to -max (0 disables)")
+	fs.StringVar(&cfg.persistPath, "persist", "", "optional JSON file to persist the default bucket across restarts")
+	fs.IntVar(&cfg.persistQueue, "persist-queue", 0, "pending -persist writes handled in the background, coalesced into one; faster, but a crash loses webhooks already acknowledged (0 writes synchronously in each request); -db always commits synchronously")
+	persistOverflow := fs.String("persist-overflow", string(overflowDrop), "when the -persist queue is full: drop the write, or block briefly first")
+	fs.StringVar(&cfg.requestIDHeader, "request-id-header", "X-Request-ID", "header carrying the sender's correlation ID, generated when absent, stored and echoed back (empty disables)")
+	fs.StringVar(&cfg.labelHeader, "label-header", "X-Webhook-Label", "request header whose value is stored as the webhook's label, for ?label= filtering (empty disables)")
+	fs.StringVar(&cfg.dedupHeader, "dedup-header", "", "request header carrying an idempotency key, e.g. X-Idempotency-Key (empty disables deduplication)")
+	fs.DurationVar(&cfg.dedupWindow, "dedup-window", 0, "ignore webhooks whose payload hash matches one received within this long, answering with the earlier ID (0 disables)")
+	fs.StringVar(&cfg.stripeSecret, "stripe-secret", os.Getenv("WEBHOOK_STRIPE_SECRET"), "Stripe endpoint signing secret (env WEBHOOK_STRIPE_SECRET)")
+	fs.DurationVar(&cfg.stripeTolerance, "stripe-tolerance", 5*time.Minute, "maximum age of a Stripe signature timestamp (0 disables the check)")
+	fs.StringVar(&cfg.standardSecret, "standard-webhooks-secret", os.Getenv("WEBHOOK_STANDARD_SECRET"), "Standard Webhooks (Svix) signing secret, whsec_<base64> (env WEBHOOK_STANDARD_SECRET)")
+	fs.DurationVar(&cfg.standardTolerance, "standard-webhooks-tolerance", 5*time.Minute, "maximum age of a Standard Webhooks timestamp (0 disables the check)")
+	standardBuckets := fs.String("standard-webhooks-buckets", "", "comma-separated buckets verified with the Standard Webhooks scheme (empty applies it to all buckets)")
+	stripeBuckets := fs.String("stripe-buckets", "", "comma-separated buckets verified with the Stripe scheme (empty applies it to all buckets)")
+	methods := fs.String("methods", http.MethodPost, "comma-separated HTTP methods accepted on the webhook endpoints")
+	fs.IntVar(&cfg.ackStatus, "ack-status", http.StatusOK, "HTTP status returned for accepted webhooks (2xx)")
+	ackBody := fs.String("ack-body", "", "response body for accepted webhooks, or @file to read it from a file (empty returns the stored webhook as JSON)")
+	fs.StringVar(&cfg.ackContentType, "ack-content-type", ackJSONContentType, "Content-Type of the response to accepted webhooks; an -ack-body that isn't JSON defaults to text/plain")
+	fs.IntVar(&cfg.maxBatch, "max-batch", 1000, "maximum elements in an array expanded by -expand-arrays (0 for unlimited)")
+	fs.BoolVar(&cfg.expandArrays, "expand-arrays", false, "store each element of a top-level JSON array body as its own webhook")
+	fs.StringVar(&cfg.schemaPath, "schema", "", "optional JSON Schema file that incoming payloads must match")
+	fs.BoolVar(&cfg.preserveIDsOnClear, "preserve-ids-on-clear", false, "keep counting IDs up after a clear instead of restarting at 1, so old IDs never match new webhooks")
+	fs.StringVar(&cfg.dbPath, "db", "", "optional SQLite database file to store webhooks in (all buckets)")
+	bucketSecrets := fs.String("bucket-secret", os.Getenv("WEBHOOK_BUCKET_SECRETS"), "comma-separated bucket=secret pairs verifying those buckets' "+signatureHeader+" with their own secret instead of -secret; repeat a bucket to rotate (env WEBHOOK_BUCKET_SECRETS)")
+	fs.StringVar(&cfg.secret, "secret", os.Getenv("WEBHOOK_SECRET"), "shared secret for "+signatureHeader+" verification; comma-separate several to accept any of them during rotation (env WEBHOOK_SECRET)")
+	fs.Int64Var(&cfg.maxBody, "max-body", 1<<20, "maximum webhook request body size in bytes (0 for unlimited)")
+	fs.Int64Var(&cfg.maxImportBody, "max-import-body", 64<<20, "maximum POST /webhooks/import body size in bytes (0 for unlimited)")
+	trustedProxies := fs.String("trusted-proxies", "", "comma-separated CIDRs of proxies whose X-Forwarded-For header is trusted for the client IP (empty ignores the header)")
+	allowCIDR := fs.String("allow-cidr", "", "comma-separated CIDRs webhooks may come from; others get 403 (empty allows all)")
+	fs.Float64Var(&cfg.rateLimit, "rate", 0, "per-IP webhook rate limit in requests per second (0 disables)")
+	fs.IntVar(&cfg.rateBurst, "burst", 10, "per-IP burst size for the rate limit")
+	fs.IntVar(&cfg.maxConcurrent, "max-concurrent", 0, "maximum webhook requests processed at once; excess ones get 503 (0 for unlimited)")
+	fs.DurationVar(&cfg.ttl, "ttl", 0, "expire webhooks older than this duration (0 disables)")
+	fs.DurationVar(&cfg.shutdownTimeout, "shutdown-timeout", 10*time.Second, "how long to wait for in-flight requests on shutdown")
+	fs.DurationVar(&cfg.readTimeout, "read-timeout", 30*time.Second, "maximum time to read a request, including the body (0 disables)")
+	fs.DurationVar(&cfg.writeTimeout, "write-timeout", 60*time.Second, "maximum time to write a response (0 disables; streams are exempt)")
+	fs.DurationVar(&cfg.idleTimeout, "idle-timeout", 120*time.Second, "how long to keep idle keep-alive connections open (0 disables)")
+	fs.DurationVar(&cfg.handlerTimeout, "handler-timeout", 30*time.Second, "deadline for a handler to respond before a 503 is returned (0 disables; streams are exempt)")
+	forward := fs.String("forward", "", "comma-separated URLs to forward accepted webhooks to")
+	forwardMap := fs.String("forward-map", "", "comma-separated event=URL pairs routing events to their own targets, e.g. push=http://a,issues=http://b; other events go to -forward")
+	fs.DurationVar(&cfg.forwardTimeout, "forward-timeout", 5*time.Second, "timeout for each forward attempt")
+	fs.IntVar(&cfg.forwardRetries, "forward-retries", 3, "number of retries for a failed forward")
+	fs.IntVar(&cfg.forwardConcurrency, "forward-concurrency", 4, "number of workers delivering forwards")
+	forwardOverflow := fs.String("forward-overflow", string(overflowDrop), "when the forward queue is full: drop, or block briefly before dropping")
+	fs.StringVar(&cfg.evictURL, "evict-url", "", "URL to POST webhooks to when they are evicted by the size caps or -ttl, instead of dropping them silently")
+	fs.DurationVar(&cfg.responseDelay, "response-delay", 0, "artificial delay before answering accepted webhooks, for testing sender timeouts")
+	fs.Float64Var(&cfg.failRate, "fail-rate", 0, "fraction of webhooks (0.0-1.0) answered with 500 without being stored, for testing sender retries")
+	fs.Uint64Var(&cfg.failSeed, "fail-seed", 0, "seed for -fail-rate decisions, for reproducible runs (0 picks a random seed)")
+	rejectWhen := fs.String("reject-when", "", "comma-separated field=value rules (dot-paths into the payload); matching webhooks get -reject-status and are not stored")
+	fs.IntVar(&cfg.rejectStatus, "reject-status", http.StatusUnprocessableEntity, "HTTP status returned when a -reject-when rule matches (4xx or 5xx)")
+	redirectEvents := fs.String("redirect-events", "", "comma-separated event=URL pairs; those events are stored and then answered with a redirect to URL, for providers whose subscription handshake expects one")
+	fs.IntVar(&cfg.redirectStatus, "redirect-status", http.StatusFound, "redirect status used by -redirect-events (301, 302, 303, 307 or 308)")
+	fs.IntVar(&cfg.metricsLabelLimit, "metrics-label-limit", 100, "distinct event and bucket values labelled in webhooks_received_total; further ones are counted as \"other\"")
+	fs.StringVar(&cfg.accessLogPath, "access-log", "", "optional file to append a JSON line to for every webhook request: time, client IP, event, status and latency")
+	fs.Int64Var(&cfg.accessLogMaxMB, "access-log-max-mb", 100, "rotate -access-log to a timestamped file once it would grow past this many megabytes (0 never rotates)")
+	tz := fs.String("tz", "UTC", "IANA timezone whose calendar days /webhooks/by-day groups by, e.g. Europe/Berlin or Local")
+	fs.BoolVar(&cfg.requireTimestamp, "require-timestamp", false, "reject webhooks without a numeric timestamp field (Unix seconds or milliseconds) with 422")
+	fs.DurationVar(&cfg.timestampSkew, "timestamp-skew", 0, "with -require-timestamp, also reject timestamps further than this from server time (0 disables the check)")
+	fs.BoolVar(&cfg.debug, "debug", false, "enable per-request testing overrides such as ?delay=")
+	fs.StringVar(&cfg.logLevel, "log-level", "info", "log level: debug, info, warn or error")
+	fs.StringVar(&cfg.logFormat, "log-format", "json", "log format: text or json")
+	mode := fs.String("mode", string(modeLIFO), "retention mode: lifo (newest first) or fifo (oldest first)")
+	corsOrigin := fs.String("cors-origin", "", "comma-separated origins allowed to read the API from a browser, or * for any (empty disables CORS)")
+	replayAllow := fs.String("replay-allow-hosts", "", "comma-separated hosts that webhooks may be replayed to (empty allows any)")
+	transformTemplate := fs.String("transform-template", "", "Go text/template applied to each decoded payload, stored as transformed; @file reads it from a file")
+	summaryFields := fs.String("summary-fields", "", "comma-separated dot-paths into the payload (e.g. repository.name,sender.login) to log and return as a summary")
+	redactKeys := fs.String("redact", "", "comma-separated top-level payload keys to mask before storing")
+	redact := fs.String("redact-headers", "Authorization,Proxy-Authorization,Cookie", "comma-separated request headers to redact when storing webhooks")
+	fs.StringVar(&cfg.readUser, "read-user", os.Getenv("WEBHOOK_READ_USER"), "username required via Basic Auth on the read and admin endpoints (env WEBHOOK_READ_USER)")
+	fs.StringVar(&cfg.readPass, "read-pass", os.Getenv("WEBHOOK_READ_PASS"), "password required via Basic Auth on the read endpoints (env WEBHOOK_READ_PASS)")
+	fs.StringVar(&cfg.adminToken, "admin-token", os.Getenv("WEBHOOK_ADMIN_TOKEN"), "token required in the X-Admin-Token header to clear, delete or import webhooks (env WEBHOOK_ADMIN_TOKEN)")
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	cfg.redactHeaders = splitList(*redact)
+	cfg.redactKeys = splitList(*redactKeys)
+	cfg.summaryFields = splitList(*summaryFields)
+	cfg.forwardTargets = splitList(*forward)
+	cfg.corsOrigins = splitList(*corsOrigin)
+	cfg.replayAllowHosts = splitList(*replayAllow)
+	cfg.stripeBuckets = splitList(*stripeBuckets)
+	cfg.standardBuckets = splitList(*standardBuckets)
+
+	if cfg.ackStatus < 200 || cfg.ackStatus > 299 {
+		return cfg, fmt.Errorf("ack status must be a 2xx code, got %d", cfg.ackStatus)
+	}
+	var err error
+	if cfg.transformTemplate, err = loadTransformTemplate(*transformTemplate); err != nil {
+		return cfg, err
+	}
+	if cfg.ackBody, err = loadAckBody(*ackBody); err != nil {
+		return cfg, err
+	}
+	if cfg.ackBody != "" && !bodyAllowedForStatus(cfg.ackStatus) {
+		return cfg, fmt.Errorf("-ack-body can't be used with ack status %d, which has no body", cfg.ackStatus)
+	}
+	if cfg.ackContentType, err = resolveAckContentType(cfg.ackContentType, flagSet(fs, "ack-content-type"), cfg.ackBody, cfg.ackStatus); err != nil {
+		return cfg, err
+	}
+
+	for _, method := range splitList(*methods) {
+		cfg.methods = append(cfg.methods, strings.ToUpper(method))
+	}
+	if len(cfg.methods) == 0 {
+		return cfg, fmt.Errorf("-methods must list at least one method")
+	}
+
+	if (cfg.tlsCert == "") != (cfg.tlsKey == "") {
+		return cfg, fmt.Errorf("-tls-cert and -tls-key must be set together")
+	}
+	if cfg.tlsRedirectAddr != "" && cfg.tlsCert == "" {
+		return cfg, fmt.Errorf("-tls-redirect-addr requires -tls-cert and -tls-key")
+	}
+	if cfg.tlsClientCA != "" && cfg.tlsCert == "" {
+		return cfg, fmt.Errorf("-tls-client-ca requires -tls-cert and -tls-key")
+	}
+
+	cfg.basePath = strings.TrimRight(*basePath, "/")
+	if cfg.basePath != "" && !strings.HasPrefix(cfg.basePath, "/") {
+		return cfg, fmt.Errorf("base path must start with /, got %q", *basePath)
+	}
+
+	cfg.mode = retentionMode(strings.ToLower(*mode))
+	if cfg.mode != modeLIFO && cfg.mode != modeFIFO {
+		return cfg, fmt.Errorf("mode must be lifo or fifo, got %q", *mode)
+	}
+
+	if cfg.maxBody < 0 {
+		return cfg, fmt.Errorf("max body must not be negative, got %d", cfg.maxBody)
+	}
+	if cfg.maxBuckets < 0 {
+		return cfg, fmt.Errorf("max buckets must not be negative, got %d", cfg.maxBuckets)
+	}
+	if cfg.maxImportBody < 0 {
+		return cfg, fmt.Errorf("max import body must not be negative, got %d", cfg.maxImportBody)
+	}
+
+	if cfg.persistPath != "" && cfg.dbPath != "" {
+		return cfg, fmt.Errorf("-persist and -db are mutually exclusive")
+	}
+	if cfg.dbPath != "" && (flagSet(fs, "persist-queue") || flagSet(fs, "persist-overflow")) {
+		// SQLite commits each webhook in the request, so there's no queue
+		return cfg, fmt.Errorf("-persist-queue and -persist-overflow only apply to -persist, not -db")
+	}
+
+	if (cfg.readUser == "") != (cfg.readPass == "") {
+		return cfg, fmt.Errorf("-read-user and -read-pass must be set together")
+	}
+
+	if cfg.secret != "" && cfg.stripeSecret != "" && len(cfg.stripeBuckets) == 0 {
+		return cfg, fmt.Errorf("-secret and -stripe-secret both apply to every bucket; use -stripe-buckets to choose where Stripe verification applies")
+	}
+	if cfg.stripeTolerance < 0 {
+		return cfg, fmt.Errorf("stripe tolerance must not be negative, got %s", cfg.stripeTolerance)
+	}
+	if cfg.standardSecret != "" {
+		if _, err := standardWebhooksKey(cfg.standardSecret); err != nil {
+			return cfg, err
+		}
+		if len(cfg.standardBuckets) == 0 && (cfg.secret != "" || (cfg.stripeSecret != "" && len(cfg.stripeBuckets) == 0)) {
+			return cfg, fmt.Errorf("-standard-webhooks-secret applies to every bucket along with another secret; use -standard-webhooks-buckets to choose where it applies")
+		}
+	}
+	if cfg.standardTolerance < 0 {
+		return cfg, fmt.Errorf("standard webhooks tolerance must not be negative, got %s", cfg.standardTolerance)
+	}
+	if cfg.bucketSecrets, err = parseBucketSecrets(*bucketSecrets); err != nil {
+		return cfg, err
+	}
+	for name := range cfg.bucketSecrets {
+		if slices.Contains(cfg.stripeBuckets, name) || slices.Contains(cfg.standardBuckets, name) {
+			return cfg, fmt.Errorf("bucket %q has a -bucket-secret and is also listed for Stripe or Standard Webhooks verification", name)
+		}
+	}
+
+	if cfg.ttl < 0 {
+		return cfg, fmt.Errorf("ttl must not be negative, got %s", cfg.ttl)
+	}
+
+	if cfg.rateLimit < 0 {
+		return cfg, fmt.Errorf("rate must not be negative, got %v", cfg.rateLimit)
+	}
+	if cfg.rateLimit > 0 && cfg.rateBurst < 1 {
+		return cfg, fmt.Errorf("burst must be at least 1 when rate limiting, got %d", cfg.rateBurst)
+	}
+
+	if cfg.forwardRetries < 0 {
+		return cfg, fmt.Errorf("forward retries must not be negative, got %d", cfg.forwardRetries)
+	}
+	if cfg.forwardConcurrency < 1 {
+		return cfg, fmt.Errorf("forward concurrency must be at least 1, got %d", cfg.forwardConcurrency)
+	}
+	cfg.forwardOverflow = overflowPolicy(strings.ToLower(*forwardOverflow))
+	if cfg.forwardOverflow != overflowDrop && cfg.forwardOverflow != overflowBlock {
+		return cfg, fmt.Errorf("forward overflow must be drop or block, got %q", *forwardOverflow)
+	}
+
+	cfg.persistOverflow = overflowPolicy(strings.ToLower(*persistOverflow))
+	if cfg.persistOverflow != overflowDrop && cfg.persistOverflow != overflowBlock {
+		return cfg, fmt.Errorf("persist overflow must be drop or block, got %q", *persistOverflow)
+	}
+	if cfg.persistQueue < 0 {
+		return cfg, fmt.Errorf("persist queue must not be negative, got %d", cfg.persistQueue)
+	}
+	if cfg.dedupWindow < 0 {
+		return cfg, fmt.Errorf("dedup window must not be negative, got %s", cfg.dedupWindow)
+	}
+
+	if cfg.bucketSizes, err = parseBucketConfig(*bucketConfig); err != nil {
+		return cfg, err
+	}
+	if cfg.forwardMap, err = parseForwardMap(*forwardMap); err != nil {
+		return cfg, err
+	}
+	if cfg.evictURL != "" {
+		u, err := url.Parse(cfg.evictURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return cfg, fmt.Errorf("evict URL must be an http or https URL, got %q", cfg.evictURL)
+		}
+	}
+	if cfg.allowCIDRs, err = parsePrefixes(splitList(*allowCIDR)); err != nil {
+		return cfg, fmt.Errorf("-allow-cidr: %w", err)
+	}
+	if cfg.trustedProxies, err = parsePrefixes(splitList(*trustedProxies)); err != nil {
+		return cfg, fmt.Errorf("-trusted-proxies: %w", err)
+	}
+	if cfg.rejectRules, err = parseRejectRules(*rejectWhen); err != nil {
+		return cfg, err
+	}
+	if cfg.rejectStatus < 400 || cfg.rejectStatus > 599 {
+		return cfg, fmt.Errorf("reject status must be a 4xx or 5xx code, got %d", cfg.rejectStatus)
+	}
+	if cfg.redirectEvents, err = parseRedirectEvents(*redirectEvents); err != nil {
+		return cfg, err
+	}
+	if !validRedirectStatus(cfg.redirectStatus) {
+		return cfg, fmt.Errorf("redirect status must be 301, 302, 303, 307 or 308, got %d", cfg.redirectStatus)
+	}
+	if cfg.accessLogMaxMB < 0 {
+		return cfg, fmt.Errorf("access log max MB must not be negative, got %d", cfg.accessLogMaxMB)
+	}
+	if cfg.metricsLabelLimit < 1 {
+		return cfg, fmt.Errorf("metrics label limit must be at least 1, got %d", cfg.metricsLabelLimit)
+	}
+	if cfg.location, err = time.LoadLocation(*tz); err != nil {
+		return cfg, fmt.Errorf("invalid -tz %q: %w", *tz, err)
+	}
+	if cfg.timestampSkew < 0 {
+		return cfg, fmt.Errorf("timestamp skew must not be negative, got %s", cfg.timestampSkew)
+	}
+	if cfg.timestampSkew > 0 && !cfg.requireTimestamp {
+		return cfg, fmt.Errorf("-timestamp-skew requires -require-timestamp")
+	}
+
+	if cfg.failRate < 0 || cfg.failRate > 1 {
+		return cfg, fmt.Errorf("fail rate must be between 0 and 1, got %v", cfg.failRate)
+	}
+
+	for name, d := range map[string]time.Duration{
+		"read": cfg.readTimeout, "write": cfg.writeTimeout, "idle": cfg.idleTimeout, "handler": cfg.handlerTimeout,
+	} {
+		if d < 0 {
+			return cfg, fmt.Errorf("%s timeout must not be negative, got %s", name, d)
+		}
+	}
+	if cfg.writeTimeout > 0 && cfg.handlerTimeout >= cfg.writeTimeout {
+		// Otherwise the connection is cut before the 503 can be written
+		return cfg, fmt.Errorf("handler timeout (%s) must be shorter than write timeout (%s)", cfg.handlerTimeout, cfg.writeTimeout)
+	}
+
+	if cfg.responseDelay < 0 {
+		return cfg, fmt.Errorf("response delay must not be negative, got %s", cfg.responseDelay)
+	}
+	if cfg.handlerTimeout > 0 && cfg.responseDelay >= cfg.handlerTimeout {
+		// The webhook would be stored but the sender told it timed out,
+		// so it would retry and store a duplicate
+		return cfg, fmt.Errorf("response delay (%s) must be shorter than handler timeout (%s)", cfg.responseDelay, cfg.handlerTimeout)
+	}
+
+	if cfg.maxConcurrent < 0 {
+		return cfg, fmt.Errorf("max concurrent must not be negative, got %d", cfg.maxConcurrent)
+	}
+
+	if cfg.maxBatch < 0 {
+		return cfg, fmt.Errorf("max batch must not be negative, got %d", cfg.maxBatch)
+	}
+
+	if cfg.maxBytes < 0 {
+		return cfg, fmt.Errorf("max bytes must not be negative, got %d", cfg.maxBytes)
+	}
+
+	if cfg.maxSize <= 0 {
+		return cfg, fmt.Errorf("max size must be greater than zero, got %d", cfg.maxSize)
+	}
+
+	return cfg, nil
+}
+
+// Parse -bucket-config name=size pairs
+func parseBucketConfig(v string) (map[string]int, error) {
+	sizes := make(map[string]int)
+	for _, pair := range splitList(v) {
+		name, sizeStr, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid bucket config %q, expected name=size", pair)
+		}
+		name = strings.TrimSpace(name)
+		if !validBucketName(name) {
+			return nil, fmt.Errorf("invalid bucket name %q in bucket config", name)
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(sizeStr))
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid size %q for bucket %q, expected a positive integer", sizeStr, name)
+		}
+		sizes[name] = size
+	}
+	return sizes, nil
+}
+
+// Parse -bucket-secret. A bucket may be listed more than once, and any of
+// its secrets is then accepted.
+func parseBucketSecrets(v string) (map[string][]string, error) {
+	secrets := make(map[string][]string)
+	for _, pair := range splitList(v) {
+		name, secret, ok := strings.Cut(pair, "=")
+		name, secret = strings.TrimSpace(name), strings.TrimSpace(secret)
+		if !ok || secret == "" {
+			return nil, fmt.Errorf("invalid bucket secret entry for %q, expected bucket=secret", name)
+		}
+		if !validBucketName(name) {
+			return nil, fmt.Errorf("invalid bucket name %q in bucket secret", name)
+		}
+		secrets[name] = append(secrets[name], secret)
+	}
+	return secrets, nil
+}
+
+// Parse -forward-map. An event may be listed more than once to send it to
+// several targets.
+func parseForwardMap(v string) (map[string][]string, error) {
+	routes := make(map[string][]string)
+	for _, pair := range splitList(v) {
+		event, target, ok := strings.Cut(pair, "=")
+		event, target = strings.TrimSpace(event), strings.TrimSpace(target)
+		if !ok || event == "" || target == "" {
+			return nil, fmt.Errorf("invalid forward map entry %q, expected event=URL", pair)
+		}
+		routes[event] = append(routes[event], target)
+	}
+	return routes, nil
+}
+
+// Max size for bucket: its -bucket-config override, or -max
+func (c config) maxSizeFor(bucket string) int {
+	if size, ok := c.bucketSizes[bucket]; ok {
+		return size
+	}
+	return c.maxSize
+}
+
+func envOrDefault(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// Split a comma-separated flag value, dropping empty entries
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Whether the named flag was given on the command line
+func flagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+)
+
+const (
+	corsAllowMethods = "GET, OPTIONS"
+	corsAllowHeaders = "Accept, Authorization, Content-Type"
+)
+
+// Add CORS headers to the read endpoints and answer their OPTIONS preflight
+// requests. Does nothing when -cors-origin is empty.
+func (s *server) cors(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(s.cfg.corsOrigins) == 0 {
+			next(w, r)
+			return
+		}
+
+		origin := r.Header.Get("Origin")
+		switch {
+		case slices.Contains(s.cfg.corsOrigins, "*"):
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		case origin != "" && slices.Contains(s.cfg.corsOrigins, origin):
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
+		}
+
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Methods", corsAllowMethods)
+			if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+				w.Header().Set("Access-Control-Allow-Headers", requested)
+			} else {
+				w.Header().Set("Access-Control-Allow-Headers", corsAllowHeaders)
+			}
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next(w, r)
+	}
+}
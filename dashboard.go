@@ -0,0 +1,90 @@
+package main
+
+import (
+	"html/template"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+)
+
+const (
+	// Webhooks listed on the dashboard
+	dashboardLimit = 50
+	// Seconds between dashboard reloads
+	dashboardRefresh = 5
+)
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="{{.Refresh}}">
+<title>webhook-receiver</title>
+<style>
+body { font: 14px/1.4 system-ui, sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; }
+th, td { text-align: left; padding: 0.25em 1em 0.25em 0; border-bottom: 1px solid #ddd; }
+td { font-family: ui-monospace, monospace; }
+.muted { color: #777; }
+</style>
+</head>
+<body>
+<h1>webhook-receiver</h1>
+<p class="muted">{{.Stored}} stored{{if .Paused}}, <strong>paused</strong>{{end}}. Showing the newest {{len .Webhooks}}, refreshed every {{.Refresh}}s at {{.Now}}.</p>
+{{if .Webhooks}}
+<table>
+<tr><th>ID</th><th>Event</th><th>Received</th></tr>
+{{range .Webhooks}}
+<tr><td><a href="{{$.BasePath}}/webhooks/{{.ID}}">{{.ID}}</a></td><td>{{.Event}}</td><td>{{.Received}}</td></tr>
+{{end}}
+</table>
+{{else}}
+<p>No webhooks yet. POST one to <code>{{.BasePath}}/webhook</code>.</p>
+{{end}}
+</body>
+</html>
+`))
+
+type dashboardRow struct {
+	ID       int
+	Event    string
+	Received string
+}
+
+// GET / shows the newest webhooks in the default bucket as a plain HTML
+// table that reloads itself
+func (s *server) dashboardHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	webhooks := s.store.GetAll()
+	if s.cfg.mode == modeFIFO {
+		slices.Reverse(webhooks)
+	}
+	stored := len(webhooks)
+	rows := make([]dashboardRow, 0, min(stored, dashboardLimit))
+	for _, webhook := range webhooks[:min(stored, dashboardLimit)] {
+		rows = append(rows, dashboardRow{
+			ID:       webhook.ID,
+			Event:    webhookEvent(webhook),
+			Received: webhook.Received.Format(time.RFC3339),
+		})
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	err := dashboardTemplate.Execute(w, map[string]interface{}{
+		"BasePath": s.cfg.basePath,
+		"Refresh":  dashboardRefresh,
+		"Stored":   stored,
+		"Paused":   s.paused.Load(),
+		"Webhooks": rows,
+		"Now":      time.Now().Format(time.TimeOnly),
+	})
+	if err != nil {
+		slog.Error("failed to render dashboard", "error", err)
+	}
+}
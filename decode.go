@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"strings"
+)
+
+// Content types decodePayload understands, as listed in 415 responses
+var acceptedContentTypes = []string{
+	"application/json",
+	"application/*+json",
+	"application/x-www-form-urlencoded",
+	"application/xml",
+	"text/xml",
+	"application/*+xml",
+}
+
+type payloadFormat int
+
+const (
+	formatUnsupported payloadFormat = iota
+	formatJSON
+	formatForm
+	formatXML
+)
+
+// Classify a Content-Type header. JSON is assumed when no type is given, for
+// lenient clients.
+func contentFormat(contentType string) (payloadFormat, error) {
+	if contentType == "" {
+		return formatJSON, nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return formatUnsupported, fmt.Errorf("invalid content type %q: %w", contentType, err)
+	}
+
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return formatJSON, nil
+	case mediaType == "application/x-www-form-urlencoded":
+		return formatForm, nil
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return formatXML, nil
+	default:
+		return formatUnsupported, fmt.Errorf("unsupported content type %q", mediaType)
+	}
+}
+
+// Returned by decodePayload when a top-level JSON array has more elements
+// than allowed
+var errTooManyElements = errors.New("too many array elements")
+
+// Decode body according to its Content-Type. Bodies that fail to decode
+// return an error; the raw body is still kept by the caller. When
+// maxElements > 0, a top-level JSON array longer than that fails with
+// errTooManyElements.
+func decodePayload(contentType string, body []byte, maxElements int) (interface{}, error) {
+	format, err := contentFormat(contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case formatJSON:
+		return decodeJSON(body, maxElements)
+	case formatForm:
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, err
+		}
+		return map[string][]string(values), nil
+	default:
+		return decodeXML(body)
+	}
+}
+
+// Decode a JSON body with a streaming decoder. Top-level arrays are read one
+// element at a time so an oversized batch is abandoned as soon as it passes
+// maxElements, rather than after building the whole slice.
+func decodeJSON(body []byte, maxElements int) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+
+	var payload interface{}
+	if trimmed := bytes.TrimLeft(body, " \t\r\n"); len(trimmed) > 0 && trimmed[0] == '[' {
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		elements := make([]interface{}, 0)
+		for dec.More() {
+			if maxElements > 0 && len(elements) == maxElements {
+				return nil, fmt.Errorf("%w: more than %d", errTooManyElements, maxElements)
+			}
+			var element interface{}
+			if err := dec.Decode(&element); err != nil {
+				return nil, err
+			}
+			elements = append(elements, element)
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		payload = elements
+	} else if err := dec.Decode(&payload); err != nil {
+		return nil, err
+	}
+
+	// Match json.Unmarshal, which rejects anything after the value
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("invalid character after top-level value")
+	}
+	return payload, nil
+}
+
+// Decode an XML document into nested maps keyed by element name. Attributes
+// are stored under "@name", character data under "#text", and repeated
+// child elements become slices.
+func decodeXML(body []byte) (interface{}, error) {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("empty XML document")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			root, err := decodeXMLElement(dec, start)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{start.Name.Local: root}, nil
+		}
+	}
+}
+
+func decodeXMLElement(dec *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	node := make(map[string]interface{})
+	for _, attr := range start.Attr {
+		node["@"+attr.Name.Local] = attr.Value
+	}
+
+	var text strings.Builder
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			child, err := decodeXMLElement(dec, t)
+			if err != nil {
+				return nil, err
+			}
+			name := t.Name.Local
+			switch existing := node[name].(type) {
+			case nil:
+				node[name] = child
+			case []interface{}:
+				node[name] = append(existing, child)
+			default:
+				node[name] = []interface{}{existing, child}
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			content := strings.TrimSpace(text.String())
+			if len(node) == 0 {
+				return content, nil
+			}
+			if content != "" {
+				node["#text"] = content
+			}
+			return node, nil
+		}
+	}
+}
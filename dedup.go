@@ -0,0 +1,107 @@
+package main
+
+import "time"
+
+// Number of idempotency keys remembered per store
+const dedupKeyLimit = 1000
+
+// Number of payload hashes remembered per store for -dedup-window. A
+// sender posting distinct bodies would otherwise grow them without bound
+// until the window passes.
+const dedupHashLimit = 1000
+
+// Store webhook unless key has been seen before, in which case the earlier
+// webhook's ID is returned and duplicate is true. An empty key always stores.
+func (ws *WebhookStore) AddIdempotent(webhook StoredWebhook, key string) (stored StoredWebhook, duplicate bool) {
+	ws.mu.Lock()
+	defer ws.unlock()
+
+	stored, duplicate, _ = ws.addIdempotentLocked(webhook, key, false)
+	return stored, duplicate
+}
+
+// AddIdempotent, but a failed synchronous save is returned instead of
+// logged, and the webhook isn't kept or its key remembered
+func (ws *WebhookStore) AddDurable(webhook StoredWebhook, key string) (stored StoredWebhook, duplicate bool, err error) {
+	ws.mu.Lock()
+	defer ws.unlock()
+
+	return ws.addIdempotentLocked(webhook, key, true)
+}
+
+// Callers must hold ws.mu
+func (ws *WebhookStore) addIdempotentLocked(webhook StoredWebhook, key string, durable bool) (stored StoredWebhook, duplicate bool, err error) {
+	if key != "" {
+		if id, ok := ws.seenKeys[key]; ok {
+			return ws.duplicateLocked(id), true, nil
+		}
+	}
+
+	hash := ""
+	if ws.dedupWindow > 0 {
+		ws.expireHashesLocked()
+		hash = payloadHash(webhook)
+		if id, ok := ws.recentHashes[hash]; ok {
+			return ws.duplicateLocked(id), true, nil
+		}
+	}
+
+	if stored, err = ws.addLocked(webhook, durable); err != nil {
+		return StoredWebhook{}, false, err
+	}
+
+	if key != "" {
+		ws.seenKeys[key] = stored.ID
+		ws.seenOrder = append(ws.seenOrder, key)
+		if len(ws.seenOrder) > dedupKeyLimit {
+			delete(ws.seenKeys, ws.seenOrder[0])
+			ws.seenOrder = ws.seenOrder[1:]
+		}
+	}
+	if hash != "" {
+		ws.recentHashes[hash] = stored.ID
+		ws.recentOrder = append(ws.recentOrder, recentHash{hash: hash, id: stored.ID, received: stored.Received})
+		if len(ws.recentOrder) > dedupHashLimit {
+			delete(ws.recentHashes, ws.recentOrder[0].hash)
+			ws.recentOrder = ws.recentOrder[1:]
+		}
+	}
+	return stored, false, nil
+}
+
+// The stored webhook a duplicate matched, or just its ID once evicted.
+// Callers must hold ws.mu.
+func (ws *WebhookStore) duplicateLocked(id int) StoredWebhook {
+	if existing, found := ws.findLocked(id); found {
+		return existing
+	}
+	return StoredWebhook{ID: id}
+}
+
+// A payload hash remembered for -dedup-window
+type recentHash struct {
+	hash     string
+	id       int
+	received time.Time
+}
+
+// Forget hashes received longer ago than the window. Callers must hold
+// ws.mu.
+func (ws *WebhookStore) expireHashesLocked() {
+	cutoff := ws.now().Add(-ws.dedupWindow)
+	n := 0
+	for n < len(ws.recentOrder) && !ws.recentOrder[n].received.After(cutoff) {
+		delete(ws.recentHashes, ws.recentOrder[n].hash)
+		n++
+	}
+	ws.recentOrder = ws.recentOrder[n:]
+}
+
+// Forget idempotency keys and payload hashes, for Clear and Restore.
+// Callers must hold ws.mu.
+func (ws *WebhookStore) resetDedupLocked() {
+	ws.seenKeys = make(map[string]int)
+	ws.seenOrder = nil
+	ws.recentHashes = make(map[string]int)
+	ws.recentOrder = nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	srv := newTestServer(t, "-dedup-header", "X-Idempotency-Key")
+	post := func(key, body string) *ackResponse {
+		t.Helper()
+		header := http.Header{"Content-Type": {"application/json"}}
+		if key != "" {
+			header.Set("X-Idempotency-Key", key)
+		}
+		rec := serve(srv, http.MethodPost, "/webhook", body, header)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+		}
+		var ack ackResponse
+		decodeResponse(t, rec, &ack)
+		return &ack
+	}
+
+	first := post("delivery-1", `{"n":1}`)
+	retry := post("delivery-1", `{"n":1}`)
+	if !retry.Duplicate || retry.ID != first.ID {
+		t.Errorf("retry = %+v, want duplicate of id %d", retry, first.ID)
+	}
+	if got := srv.store.Len(); got != 1 {
+		t.Errorf("%d webhooks stored after a retry, want 1", got)
+	}
+
+	if other := post("delivery-2", `{"n":1}`); other.Duplicate {
+		t.Error("a different key was treated as a duplicate")
+	}
+	if unkeyed := post("", `{"n":1}`); unkeyed.Duplicate {
+		t.Error("a request without the header was treated as a duplicate")
+	}
+	if got := srv.store.Len(); got != 3 {
+		t.Errorf("%d webhooks stored, want 3", got)
+	}
+}
+
+func TestDedupWindow(t *testing.T) {
+	srv := newTestServer(t, "-dedup-window", "1m")
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	srv.store.(*WebhookStore).now = clock.Now
+	post := func(body string) ackResponse {
+		t.Helper()
+		rec := postJSON(srv, "/webhook", body)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+		}
+		var ack ackResponse
+		decodeResponse(t, rec, &ack)
+		return ack
+	}
+
+	first := post(`{"n":1}`)
+	clock.Advance(30 * time.Second)
+	if again := post(`{"n":1}`); !again.Duplicate || again.ID != first.ID {
+		t.Errorf("resent within the window: %+v, want duplicate of id %d", again, first.ID)
+	}
+	if other := post(`{"n":2}`); other.Duplicate {
+		t.Error("a different payload was treated as a duplicate")
+	}
+
+	// The window runs from the first delivery, not the duplicate
+	clock.Advance(31 * time.Second)
+	later := post(`{"n":1}`)
+	if later.Duplicate || later.ID == first.ID {
+		t.Errorf("resent after the window: %+v, want a new webhook", later)
+	}
+	if got := srv.store.Len(); got != 3 {
+		t.Errorf("%d webhooks stored, want 3", got)
+	}
+}
+
+func TestDedupWindowBounded(t *testing.T) {
+	ws := NewWebhookStore(10, 0, modeLIFO)
+	ws.dedupWindow = time.Hour
+	numbered := func(n int) StoredWebhook {
+		return StoredWebhook{Payload: map[string]interface{}{"n": n}}
+	}
+	for n := 0; n < dedupHashLimit+50; n++ {
+		ws.AddIdempotent(numbered(n), "")
+	}
+	if len(ws.recentHashes) != dedupHashLimit || len(ws.recentOrder) != dedupHashLimit {
+		t.Errorf("%d hashes, %d in order; want both capped at %d", len(ws.recentHashes), len(ws.recentOrder), dedupHashLimit)
+	}
+
+	// The oldest hashes are the ones dropped
+	if _, duplicate := ws.AddIdempotent(numbered(dedupHashLimit+49), ""); !duplicate {
+		t.Error("the most recent payload was forgotten")
+	}
+	if _, duplicate := ws.AddIdempotent(numbered(0), ""); duplicate {
+		t.Error("the oldest payload is still remembered past the limit")
+	}
+}
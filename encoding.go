@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var errDecompressedTooLarge = errors.New("decompressed body too large")
+
+// Undo a gzip Content-Encoding. The decompressed size is capped at limit
+// (0 for unlimited) so a small compressed body can't expand without bound.
+func decodeBody(body []byte, contentEncoding string, limit int64) ([]byte, error) {
+	switch strings.ToLower(strings.TrimSpace(contentEncoding)) {
+	case "", "identity":
+		return body, nil
+	case "gzip", "x-gzip":
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", contentEncoding)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("malformed gzip body: %w", err)
+	}
+	defer gz.Close()
+
+	var reader io.Reader = gz
+	if limit > 0 {
+		reader = io.LimitReader(gz, limit+1)
+	}
+	decoded, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("malformed gzip body: %w", err)
+	}
+	if limit > 0 && int64(len(decoded)) > limit {
+		return nil, errDecompressedTooLarge
+	}
+	return decoded, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func gzipString(t *testing.T, s string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func gzipHeader(extra ...string) http.Header {
+	header := http.Header{
+		"Content-Type":     {"application/json"},
+		"Content-Encoding": {"gzip"},
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		header.Set(extra[i], extra[i+1])
+	}
+	return header
+}
+
+func TestGzipBody(t *testing.T) {
+	const payload = `{"action":"opened","number":7}`
+
+	t.Run("decompressed before decoding", func(t *testing.T) {
+		srv := newTestServer(t)
+		rec := serve(srv, http.MethodPost, "/webhook", gzipString(t, payload), gzipHeader())
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+		}
+		stored, ok := srv.store.GetByID(1)
+		if !ok {
+			t.Fatal("webhook not stored")
+		}
+		if stored.RawBody != payload {
+			t.Errorf("raw body %q, want %q", stored.RawBody, payload)
+		}
+		if got := getStringFromPayload(stored.Payload, "action"); got != "opened" {
+			t.Errorf("payload action %q, want opened", got)
+		}
+	})
+
+	t.Run("signature covers the decompressed body", func(t *testing.T) {
+		srv := newTestServer(t, "-secret", "s3cret")
+		mac := hmac.New(sha256.New, []byte("s3cret"))
+		mac.Write([]byte(payload))
+		header := gzipHeader(signatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+		rec := serve(srv, http.MethodPost, "/webhook", gzipString(t, payload), header)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status %d, body %s", rec.Code, rec.Body)
+		}
+		if stored, _ := srv.store.GetByID(1); !stored.Verified {
+			t.Error("webhook not marked verified")
+		}
+	})
+
+	t.Run("malformed gzip", func(t *testing.T) {
+		srv := newTestServer(t)
+		rec := serve(srv, http.MethodPost, "/webhook", payload, gzipHeader())
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("status %d, want 400", rec.Code)
+		}
+		if !strings.Contains(rec.Body.String(), "malformed gzip") {
+			t.Errorf("body %s doesn't mention malformed gzip", rec.Body)
+		}
+		if srv.store.Len() != 0 {
+			t.Error("malformed body was stored")
+		}
+	})
+
+	t.Run("decompressed size is bounded by -max-body", func(t *testing.T) {
+		srv := newTestServer(t, "-max-body", "1024")
+		bomb := gzipString(t, jsonOfSize(64<<10))
+		if len(bomb) >= 1024 {
+			t.Fatalf("compressed body is %d bytes, expected it under the limit", len(bomb))
+		}
+
+		rec := serve(srv, http.MethodPost, "/webhook", bomb, gzipHeader())
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("status %d, want 413", rec.Code)
+		}
+		if srv.store.Len() != 0 {
+			t.Error("oversized body was stored")
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+type endpoint struct {
+	route       string
+	description string
+}
+
+// Routes served by the API, including -base-path, used for the startup log
+func (s *server) endpoints() []endpoint {
+	listOrder := "most recent first"
+	if s.cfg.mode == modeFIFO {
+		listOrder = "oldest first"
+	}
+
+	endpoints := []endpoint{
+		{"GET /", "HTML dashboard of the newest webhooks"},
+		{"POST /webhook", "Receive webhooks"},
+		{"POST /webhook/{bucket}", "Receive webhooks into a named bucket"},
+		{"POST /webhook/validate", "Dry-run a webhook through decoding, signature and schema checks without storing it"},
+		{"GET /webhooks", "Get all webhooks (" + listOrder + ")"},
+		{"GET /webhooks?event={name}", "Filter webhooks by event (add &match=prefix for prefix matching)"},
+		{"GET /webhooks?limit={n}&offset={n}", "Paginate the webhook list"},
+		{"GET /webhooks?order={asc|desc}", "List oldest or newest first"},
+		{"GET /webhooks (Accept: application/x-ndjson or text/csv)", "List as NDJSON or CSV instead of JSON, with the same filters"},
+		{"GET /webhooks?since={time}&until={time}", "Filter webhooks by RFC3339 received time (inclusive)"},
+		{"GET /webhooks?max_age={duration}", "Only show webhooks received within the given duration"},
+		{"GET /webhooks?label={label}", "Filter webhooks by the label sent in the label header"},
+		{"GET /webhooks?hash={sha256}", "Find webhooks whose canonical payload has the given hash"},
+		{"GET /webhooks/{id}", "Get webhook by ID"},
+		{"GET /webhooks/latest", "Get the most recent webhook (add ?event={name} for the latest of one event)"},
+		{"GET /webhooks/by-day", "Webhooks grouped by received day in the -tz timezone, newest day first"},
+		{"GET /webhooks/{bucket}", "Get all webhooks in a named bucket"},
+		{"DELETE /webhooks/{id}", "Delete webhook by ID"},
+		{"POST /webhooks/{id}/replay", "Re-send a stored webhook to a target URL"},
+		{"GET /webhooks/search?q={text}", "Search webhook payloads (add &field={key} to search one field)"},
+		{"GET /webhooks/export", "Export all webhooks as NDJSON (add ?order=asc for oldest first)"},
+		{"GET /webhooks/export?follow=true", "Export, then keep streaming new webhooks as NDJSON (like tail -f)"},
+		{"GET /webhooks/export.csv", "Export webhook metadata as CSV"},
+		{"GET /webhooks/stats", "Summary of stored webhooks: counts by event, oldest/newest, next ID"},
+		{"GET /webhooks...?pretty=true", "Indent JSON responses (or send Accept: application/json+pretty)"},
+		{"POST /webhooks/import", "Import webhooks from NDJSON"},
+		{"GET /webhooks/stream", "Stream new webhooks (Server-Sent Events)"},
+		{"GET /ws", "WebSocket stream of new webhooks; accepts {\"cmd\":\"snapshot\"} and {\"cmd\":\"clear\"}"},
+		{"/webhooks/clear", "Clear all webhooks"},
+		{"GET /buckets", "List buckets and their counts"},
+		{"PUT /config/max-size", "Change a bucket's max size at runtime with {\"max_size\": N} (add ?bucket={name})"},
+		{"GET /admin/snapshot", "Dump a bucket's full state: webhooks, next ID and max size (add ?bucket={name})"},
+		{"POST /admin/restore", "Replace a bucket's state with a snapshot from /admin/snapshot (add ?bucket={name})"},
+		{"POST /admin/pause", "Stop accepting webhooks (503 with Retry-After) while reads keep working"},
+		{"POST /admin/resume", "Accept webhooks again after /admin/pause"},
+		{"GET /healthz", "Liveness probe, including whether webhooks are being accepted"},
+		{"GET /readyz", "Readiness probe"},
+		{"GET /metrics", "Prometheus metrics"},
+		{"GET /version", "Build version, commit and time"},
+		{"GET /openapi.json", "OpenAPI 3 description of this API"},
+	}
+
+	if s.cfg.basePath != "" {
+		for i, e := range endpoints {
+			slash := strings.Index(e.route, "/")
+			endpoints[i].route = e.route[:slash] + s.cfg.basePath + e.route[slash:]
+		}
+	}
+	return endpoints
+}
+
+type endpointInfo struct {
+	Route       string `json:"route"`
+	Description string `json:"description"`
+}
+
+type notFoundResponse struct {
+	errorResponse
+	Endpoints []endpointInfo `json:"endpoints"`
+}
+
+// Catch-all for paths no route matches, so clients pointed at the wrong URL
+// get a JSON error and a list of what is served
+func (s *server) notFoundHandler(w http.ResponseWriter, r *http.Request) {
+	endpoints := s.endpoints()
+	response := notFoundResponse{
+		errorResponse: errorResponse{Error: "Not found", Code: http.StatusNotFound},
+		Endpoints:     make([]endpointInfo, len(endpoints)),
+	}
+	for i, e := range endpoints {
+		response.Endpoints[i] = endpointInfo{Route: e.route, Description: e.description}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusNotFound)
+	json.NewEncoder(w).Encode(response)
+}
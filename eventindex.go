@@ -0,0 +1,95 @@
+package main
+
+import (
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Account for a webhook just pushed onto the ring. Callers must hold ws.mu.
+func (ws *WebhookStore) indexLocked(webhook StoredWebhook) {
+	ws.bytes += webhook.size
+	event := webhookEvent(webhook)
+	ws.events[event] = append(ws.events[event], webhook.ID)
+}
+
+// Undo indexLocked for a webhook that has left the ring. Callers must hold
+// ws.mu.
+func (ws *WebhookStore) forgetLocked(webhook StoredWebhook) {
+	ws.bytes -= webhook.size
+	event := webhookEvent(webhook)
+	ids := ws.events[event]
+	// Evictions take the oldest, which is always first
+	if i := slices.Index(ids, webhook.ID); i >= 0 {
+		ids = slices.Delete(ids, i, i+1)
+	}
+	if len(ids) == 0 {
+		delete(ws.events, event)
+		return
+	}
+	ws.events[event] = ids
+}
+
+// Position of id in the ring. IDs are handed out in arrival order, so the
+// ring is sorted by ID. Callers must hold ws.mu.
+func (ws *WebhookStore) positionLocked(id int) (int, bool) {
+	n := ws.webhooks.Len()
+	i := sort.Search(n, func(i int) bool {
+		return ws.webhooks.At(i).ID >= id
+	})
+	return i, i < n && ws.webhooks.At(i).ID == id
+}
+
+// GetAllWithCapacity limited to webhooks whose event equals event, or
+// starts with it when prefix is set. stored counts the whole store.
+func (ws *WebhookStore) GetByEventWithCapacity(event string, prefix bool) (webhooks []StoredWebhook, stored, nextID, maxSize int) {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	var ids []int
+	if prefix {
+		for name, named := range ws.events {
+			if strings.HasPrefix(name, event) {
+				ids = append(ids, named...)
+			}
+		}
+		slices.Sort(ids)
+	} else {
+		ids = ws.events[event]
+	}
+
+	webhooks = make([]StoredWebhook, 0, len(ids))
+	for _, id := range ids {
+		if i, ok := ws.positionLocked(id); ok {
+			webhooks = append(webhooks, ws.webhooks.At(i))
+		}
+	}
+	if ws.mode != modeFIFO {
+		slices.Reverse(webhooks)
+	}
+	return webhooks, ws.webhooks.Len(), ws.nextID, ws.maxSize
+}
+
+// /webhooks/stats from the index and the ends of the ring, without a scan
+func (ws *WebhookStore) Stats() webhookStats {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	stats := webhookStats{
+		Stored: ws.webhooks.Len(),
+		Events: make(map[string]int, len(ws.events)),
+	}
+	for event, ids := range ws.events {
+		if event == "" {
+			event = unknownEvent
+		}
+		stats.Events[event] += len(ids)
+	}
+	if n := ws.webhooks.Len(); n > 0 {
+		oldest, newest := ws.webhooks.At(0).Received, ws.webhooks.At(n-1).Received
+		stats.Oldest, stats.Newest = &oldest, &newest
+	}
+	received, nextID := ws.received, ws.nextID
+	stats.Received, stats.NextID = &received, &nextID
+	return stats
+}
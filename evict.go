@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+// Why a webhook left the store without being deleted or cleared
+const (
+	evictOverflow = "overflow"
+	evictTTL      = "ttl"
+)
+
+// Evicted webhooks waiting to be posted to -evict-url. Full means the
+// notice is dropped.
+const evictQueueSize = 1000
+
+// Called by a store with the webhooks it just evicted, while holding its
+// lock, so it must not block
+type evictFunc func(reason string, webhooks ...StoredWebhook)
+
+// Body POSTed to -evict-url, one per evicted webhook
+type evictionNotice struct {
+	Bucket  string        `json:"bucket"`
+	Reason  string        `json:"reason"`
+	Webhook StoredWebhook `json:"webhook"`
+}
+
+// Posts evicted webhooks to a dead-letter URL from a single background
+// worker, using the forwarder's retry policy
+type evictionHook struct {
+	target string
+	sender *forwarder
+	queue  chan evictionNotice
+}
+
+// Create the hook and start its worker, which runs for the life of the
+// process
+func newEvictionHook(cfg config) *evictionHook {
+	h := &evictionHook{
+		target: cfg.evictURL,
+		sender: &forwarder{
+			client:  &http.Client{Timeout: cfg.forwardTimeout},
+			retries: cfg.forwardRetries,
+		},
+		queue: make(chan evictionNotice, evictQueueSize),
+	}
+	go h.work()
+	return h
+}
+
+// evictFunc for one bucket's store. Nil-safe, so callers can pass the
+// result of a nil hook straight to the store.
+func (h *evictionHook) forBucket(bucket string) evictFunc {
+	if h == nil {
+		return nil
+	}
+	return func(reason string, webhooks ...StoredWebhook) {
+		for _, webhook := range webhooks {
+			select {
+			case h.queue <- evictionNotice{Bucket: bucket, Reason: reason, Webhook: webhook}:
+			default:
+				slog.Warn("eviction queue full, dropping evicted webhook", "bucket", bucket, "webhook_id", webhook.ID, "reason", reason)
+			}
+		}
+	}
+}
+
+func (h *evictionHook) work() {
+	for notice := range h.queue {
+		body, err := json.Marshal(notice)
+		if err != nil {
+			slog.Error("failed to encode evicted webhook", "webhook_id", notice.Webhook.ID, "error", err)
+			continue
+		}
+		h.sender.deliver(h.target, body, "application/json")
+	}
+}
+
+// Report webhooks evicted for reason to the hook, if any. Callers must hold
+// ws.mu.
+func (ws *WebhookStore) evictedLocked(reason string, webhooks ...StoredWebhook) {
+	if ws.onEvict != nil && len(webhooks) > 0 {
+		ws.onEvict(reason, webhooks...)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// Webhooks Each copies per acquisition of the read lock
+const eachChunkSize = 256
+
+// Call fn for each stored webhook, oldest first when ascending is true and
+// newest first otherwise, stopping at the first error. The store is copied
+// a chunk at a time and unlocked while fn runs, so a slow export doesn't
+// hold up adds and deletes; webhooks added or removed meanwhile may or may
+// not be seen.
+func (ws *WebhookStore) Each(ascending bool, fn func(StoredWebhook) error) error {
+	cursor := 0 // ID of the last webhook passed to fn
+	for {
+		chunk := ws.chunkAfter(cursor, ascending)
+		for _, webhook := range chunk {
+			if err := fn(webhook); err != nil {
+				return err
+			}
+		}
+		if len(chunk) < eachChunkSize {
+			return nil
+		}
+		cursor = chunk[len(chunk)-1].ID
+	}
+}
+
+// Copy up to eachChunkSize webhooks following cursor in Each order, from
+// the start when cursor is 0. Relies on the ring being sorted by ID.
+func (ws *WebhookStore) chunkAfter(cursor int, ascending bool) []StoredWebhook {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	n := ws.webhooks.Len()
+	if ascending {
+		start := 0
+		if cursor > 0 {
+			start, _ = ws.positionLocked(cursor + 1)
+		}
+		end := min(start+eachChunkSize, n)
+		chunk := make([]StoredWebhook, 0, end-start)
+		for i := start; i < end; i++ {
+			chunk = append(chunk, ws.webhooks.At(i))
+		}
+		return chunk
+	}
+
+	end := n
+	if cursor > 0 {
+		end, _ = ws.positionLocked(cursor)
+	}
+	start := max(end-eachChunkSize, 0)
+	chunk := make([]StoredWebhook, 0, end-start)
+	for i := end - 1; i >= start; i-- {
+		chunk = append(chunk, ws.webhooks.At(i))
+	}
+	return chunk
+}
+
+// GET /webhooks/export streams every stored webhook as NDJSON. With
+// ?follow=true the connection then stays open for new webhooks.
+func (s *server) exportWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ascending, ok := s.listOrder(r)
+	if !ok {
+		writeError(w, "Invalid order, expected asc or desc", http.StatusBadRequest)
+		return
+	}
+
+	if isFollow(r) {
+		s.followExport(w, r, ascending)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	eachWebhook(s.store, ascending, func(webhook StoredWebhook) error {
+		return enc.Encode(webhook)
+	})
+}
+
+func isFollow(r *http.Request) bool {
+	return r.URL.Query().Get("follow") == "true"
+}
+
+// Like tail -f: write the current webhooks in the requested order, then
+// each new one as it is stored, until the client goes away. Blank lines
+// are sent while idle so dead connections are noticed; NDJSON readers such
+// as jq skip them.
+func (s *server) followExport(w http.ResponseWriter, r *http.Request, ascending bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	sub, ok := s.store.(subscribableStore)
+	if !ok {
+		writeError(w, "Following not supported by this store", http.StatusNotImplemented)
+		return
+	}
+
+	snapshot, updates, cancel := sub.Subscribe()
+	defer cancel()
+
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	// The snapshot is in -mode order
+	if ascending != (s.cfg.mode == modeFIFO) {
+		slices.Reverse(snapshot)
+	}
+	enc := json.NewEncoder(w)
+	for _, webhook := range snapshot {
+		if err := enc.Encode(webhook); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case webhook := <-updates:
+			if err := enc.Encode(webhook); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-keepAlive.C:
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// Parse ?order=asc|desc, defaulting to the -mode list order
+func (s *server) listOrder(r *http.Request) (ascending, ok bool) {
+	switch r.URL.Query().Get("order") {
+	case "":
+		return s.cfg.mode == modeFIFO, true
+	case "asc":
+		return true, true
+	case "desc":
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+// Iterate over store in arrival order, using Each when the backend supports
+// it and a GetAll copy otherwise
+func eachWebhook(store Store, ascending bool, fn func(StoredWebhook) error) error {
+	if it, ok := store.(iterableStore); ok {
+		return it.Each(ascending, fn)
+	}
+
+	webhooks := store.GetAll()
+	slices.SortFunc(webhooks, func(a, b StoredWebhook) int {
+		if ascending {
+			return a.ID - b.ID
+		}
+		return b.ID - a.ID
+	})
+	for _, webhook := range webhooks {
+		if err := fn(webhook); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEachOrderAcrossChunks(t *testing.T) {
+	const n = 2*eachChunkSize + 10
+	ws := NewWebhookStore(n, 0, modeLIFO)
+	for i := 0; i < n; i++ {
+		ws.Add(StoredWebhook{})
+	}
+
+	for _, ascending := range []bool{true, false} {
+		var ids []int
+		ws.Each(ascending, func(webhook StoredWebhook) error {
+			ids = append(ids, webhook.ID)
+			return nil
+		})
+		if len(ids) != n {
+			t.Fatalf("ascending=%v: visited %d webhooks, want %d", ascending, len(ids), n)
+		}
+		for i, id := range ids {
+			want := i + 1
+			if !ascending {
+				want = n - i
+			}
+			if id != want {
+				t.Fatalf("ascending=%v: position %d has id %d, want %d", ascending, i, id, want)
+			}
+		}
+	}
+}
+
+func TestEachStopsOnError(t *testing.T) {
+	ws := NewWebhookStore(10, 0, modeLIFO)
+	for i := 0; i < 5; i++ {
+		ws.Add(StoredWebhook{})
+	}
+	stop := errors.New("stop")
+	visited := 0
+	err := ws.Each(true, func(StoredWebhook) error {
+		visited++
+		if visited == 2 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || visited != 2 {
+		t.Errorf("Each returned %v after %d webhooks, want stop after 2", err, visited)
+	}
+}
+
+// A consumer stuck writing to a slow client must not block intake
+func TestEachDoesNotBlockAdd(t *testing.T) {
+	ws := NewWebhookStore(10, 0, modeLIFO)
+	ws.Add(StoredWebhook{})
+
+	inside, release := make(chan struct{}), make(chan struct{})
+	go ws.Each(true, func(StoredWebhook) error {
+		close(inside)
+		<-release
+		return nil
+	})
+	defer close(release)
+	<-inside
+
+	added := make(chan struct{})
+	go func() {
+		ws.Add(StoredWebhook{})
+		ws.DeleteByID(1)
+		close(added)
+	}()
+	select {
+	case <-added:
+	case <-time.After(time.Second):
+		t.Fatal("Add and DeleteByID blocked while Each was calling fn")
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var csvExportHeader = []string{"id", "received", "event", "timestamp", "remote_addr", "payload_size"}
+
+// GET /webhooks/export.csv[?order=asc|desc]
+//
+// One row of metadata per webhook; payloads themselves are left out.
+func (s *server) exportCSVHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ascending, ok := s.listOrder(r)
+	if !ok {
+		writeError(w, "Invalid order, expected asc or desc", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="webhooks-`+time.Now().UTC().Format("20060102-150405")+`.csv"`)
+
+	cw := csv.NewWriter(w)
+	cw.Write(csvExportHeader)
+	eachWebhook(s.store, ascending, func(webhook StoredWebhook) error {
+		return cw.Write(csvRecord(webhook))
+	})
+	cw.Flush()
+}
+
+// Write webhooks as CSV with the same columns as /webhooks/export.csv
+func writeCSV(w io.Writer, webhooks []StoredWebhook) {
+	cw := csv.NewWriter(w)
+	cw.Write(csvExportHeader)
+	for _, webhook := range webhooks {
+		cw.Write(csvRecord(webhook))
+	}
+	cw.Flush()
+}
+
+func csvRecord(webhook StoredWebhook) []string {
+	timestamp := ""
+	if ts := getInt64FromPayload(webhook.Payload, "timestamp"); ts != 0 {
+		timestamp = strconv.FormatInt(ts, 10)
+	}
+	return []string{
+		strconv.Itoa(webhook.ID),
+		webhook.Received.Format(time.RFC3339Nano),
+		webhookEvent(webhook),
+		timestamp,
+		webhook.RemoteAddr,
+		strconv.Itoa(rawBodySize(webhook)),
+	}
+}
+
+// Size in bytes of the body as it was sent
+func rawBodySize(webhook StoredWebhook) int {
+	if webhook.RawBodyEncoding == "base64" {
+		if body, err := base64.StdEncoding.DecodeString(webhook.RawBody); err == nil {
+			return len(body)
+		}
+	}
+	return len(webhook.RawBody)
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+)
+
+const (
+	forwardBaseBackoff = 500 * time.Millisecond
+	forwardMaxBackoff  = 5 * time.Second
+
+	// Pending deliveries held before -forward-overflow applies
+	forwardQueueSize = 1000
+	// How long the block policy waits for queue space before dropping
+	forwardBlockTimeout = time.Second
+)
+
+// What to do with a forward when the queue is full
+type overflowPolicy string
+
+const (
+	overflowDrop  overflowPolicy = "drop"
+	overflowBlock overflowPolicy = "block"
+)
+
+type forwardJob struct {
+	target      string
+	body        []byte
+	contentType string
+}
+
+// Relays accepted webhooks to downstream URLs using a fixed pool of
+// workers fed from a bounded queue
+type forwarder struct {
+	targets  []string
+	routes   map[string][]string
+	client   *http.Client
+	retries  int
+	overflow overflowPolicy
+	queue    chan forwardJob
+}
+
+// Create the forwarder and start its workers, which run for the life of
+// the process
+func newForwarder(cfg config) *forwarder {
+	f := &forwarder{
+		targets:  cfg.forwardTargets,
+		routes:   cfg.forwardMap,
+		client:   &http.Client{Timeout: cfg.forwardTimeout},
+		retries:  cfg.forwardRetries,
+		overflow: cfg.forwardOverflow,
+		queue:    make(chan forwardJob, forwardQueueSize),
+	}
+	for range cfg.forwardConcurrency {
+		go f.work()
+	}
+	return f
+}
+
+// Targets for a body carrying the given events: each event's -forward-map
+// targets, or the default -forward targets for events without a route.
+// A batch with several events goes to each target once.
+func (f *forwarder) Route(events ...string) []string {
+	var targets []string
+	for _, event := range events {
+		routed, ok := f.routes[event]
+		if !ok {
+			routed = f.targets
+		}
+		for _, target := range routed {
+			if !slices.Contains(targets, target) {
+				targets = append(targets, target)
+			}
+		}
+	}
+	return targets
+}
+
+// Queue body for delivery to the targets routed for events. Never blocks
+// the caller under the drop policy; under block it waits up to
+// forwardBlockTimeout for space before dropping.
+func (f *forwarder) Forward(body []byte, contentType string, events ...string) {
+	targets := f.Route(events...)
+	if len(targets) == 0 {
+		slog.Info("no forward target for event, not forwarding", "events", events)
+		return
+	}
+	slog.Info("forwarding webhook", "events", events, "targets", targets)
+
+	for _, target := range targets {
+		job := forwardJob{target: target, body: body, contentType: contentType}
+		if !f.enqueue(job) {
+			slog.Warn("forward queue full, dropping webhook", "target", target, "policy", f.overflow)
+		}
+	}
+}
+
+func (f *forwarder) enqueue(job forwardJob) bool {
+	select {
+	case f.queue <- job:
+		return true
+	default:
+	}
+	if f.overflow != overflowBlock {
+		return false
+	}
+
+	timer := time.NewTimer(forwardBlockTimeout)
+	defer timer.Stop()
+	select {
+	case f.queue <- job:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// Number of deliveries waiting for a worker
+func (f *forwarder) QueueDepth() int {
+	return len(f.queue)
+}
+
+func (f *forwarder) work() {
+	for job := range f.queue {
+		f.deliver(job.target, job.body, job.contentType)
+	}
+}
+
+// POST body to target, retrying with exponential backoff on failure
+func (f *forwarder) deliver(target string, body []byte, contentType string) {
+	backoff := forwardBaseBackoff
+	for attempt := 0; ; attempt++ {
+		err := f.post(target, body, contentType)
+		if err == nil {
+			return
+		}
+		if attempt >= f.retries {
+			slog.Error("forward failed", "target", target, "attempts", attempt+1, "error", err)
+			return
+		}
+
+		slog.Warn("forward failed, retrying", "target", target, "attempt", attempt+1, "backoff", backoff, "error", err)
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > forwardMaxBackoff {
+			backoff = forwardMaxBackoff
+		}
+	}
+}
+
+func (f *forwarder) post(target string, body []byte, contentType string) error {
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
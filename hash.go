@@ -0,0 +1,42 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+// Hex SHA-256 of the payload as canonical JSON, so payloads that differ
+// only in key order or whitespace hash the same. Webhooks without a decoded
+// payload fall back to the raw body.
+func payloadHash(webhook StoredWebhook) string {
+	var data []byte
+	if webhook.Payload != nil {
+		// Marshal sorts map keys and drops insignificant whitespace
+		data, _ = json.Marshal(webhook.Payload)
+	}
+	if data == nil {
+		data = []byte(webhook.RawBody)
+		if webhook.RawBodyEncoding == "base64" {
+			if decoded, err := base64.StdEncoding.DecodeString(webhook.RawBody); err == nil {
+				data = decoded
+			}
+		}
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Apply the ?hash= filter, matching hex digests case-insensitively
+func filterByHash(webhooks []StoredWebhook, query url.Values) ([]StoredWebhook, error) {
+	hash := strings.ToLower(query.Get("hash"))
+	if hash == "" {
+		return webhooks, nil
+	}
+	return filterWebhooks(webhooks, func(webhook StoredWebhook) bool {
+		return webhook.Hash == hash
+	}), nil
+}
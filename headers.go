@@ -0,0 +1,28 @@
+package main
+
+import "net/http"
+
+const redactedValue = "[REDACTED]"
+
+// Copy request headers for storage, masking the values of sensitive ones
+func redactHeaders(header http.Header, redact []string) map[string][]string {
+	headers := make(map[string][]string, len(header))
+	for name, values := range header {
+		headers[name] = append([]string(nil), values...)
+	}
+
+	for _, name := range redact {
+		name = http.CanonicalHeaderKey(name)
+		values, ok := headers[name]
+		if !ok {
+			continue
+		}
+		masked := make([]string, len(values))
+		for i := range masked {
+			masked[i] = redactedValue
+		}
+		headers[name] = masked
+	}
+
+	return headers
+}
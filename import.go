@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+// POST /webhooks/import adds each NDJSON line (in the /webhooks/export
+// format) as a new webhook. Entries get fresh IDs through the normal Add path.
+func (s *server) importWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if s.cfg.maxImportBody > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, s.cfg.maxImportBody)
+	}
+
+	imported, failed := 0, 0
+	reader := bufio.NewReader(r.Body)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			var webhook StoredWebhook
+			if jsonErr := json.Unmarshal(line, &webhook); jsonErr != nil {
+				failed++
+			} else {
+				s.store.Add(webhook)
+				imported++
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			// Lines read before the limit stay imported
+			slog.Warn("import body too large", "limit", tooLarge.Limit, "imported", imported, "failed", failed)
+			writeError(w, fmt.Sprintf("Request body exceeds %d bytes; %d webhooks were imported before the limit", tooLarge.Limit, imported), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			writeError(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	slog.Info("webhooks imported", "imported", imported, "failed", failed)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":  "Import completed",
+		"imported": imported,
+		"failed":   failed,
+	})
+}
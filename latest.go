@@ -0,0 +1,63 @@
+package main
+
+import "net/http"
+
+// Most recently stored webhook, optionally only among those with the given
+// event, without copying the rest of the store
+func (ws *WebhookStore) Latest(event string) (StoredWebhook, bool) {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	n := ws.webhooks.Len()
+	if event == "" {
+		if n == 0 {
+			return StoredWebhook{}, false
+		}
+		return ws.webhooks.At(n - 1), true
+	}
+
+	ids := ws.events[event]
+	if len(ids) == 0 {
+		return StoredWebhook{}, false
+	}
+	i, ok := ws.positionLocked(ids[len(ids)-1])
+	if !ok {
+		return StoredWebhook{}, false
+	}
+	return ws.webhooks.At(i), true
+}
+
+// Latest for stores without their own: the highest ID wins
+func latestWebhook(store Store, event string) (StoredWebhook, bool) {
+	if l, ok := store.(latestStore); ok {
+		return l.Latest(event)
+	}
+
+	var latest StoredWebhook
+	found := false
+	for _, webhook := range store.GetAll() {
+		if event != "" && webhookEvent(webhook) != event {
+			continue
+		}
+		if !found || webhook.ID > latest.ID {
+			latest, found = webhook, true
+		}
+	}
+	return latest, found
+}
+
+// GET /webhooks/latest[?event=<name>]
+func (s *server) latestWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	webhook, found := latestWebhook(s.store, r.URL.Query().Get("event"))
+	if !found {
+		writeError(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, r, webhook)
+}
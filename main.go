@@ -1,96 +1,650 @@
 package main
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"maps"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/santhosh-tekuri/jsonschema/v5"
 )
 
 type StoredWebhook struct {
-	ID       int         `json:"id"`
-	Payload  interface{} `json:"payload"`
-	Received time.Time   `json:"received"`
+	ID       int                 `json:"id"`
+	Payload  interface{}         `json:"payload"`
+	Headers  map[string][]string `json:"headers,omitempty"`
+	Received time.Time           `json:"received"`
+
+	// From the payload's "timestamp" field, when it has one
+	EventTime *time.Time `json:"event_time,omitempty"`
+
+	RemoteAddr string `json:"remote_addr"`
+	UserAgent  string `json:"user_agent"`
+
+	// Common name of the verified TLS client certificate, if one was sent
+	ClientCN string `json:"client_cn,omitempty"`
+
+	Method string              `json:"method,omitempty"`
+	Query  map[string][]string `json:"query,omitempty"`
+
+	// Delivery metadata from provider headers (see metadata.go)
+	Event      string `json:"event,omitempty"`
+	DeliveryID string `json:"delivery_id,omitempty"`
+	Verified   bool   `json:"verified"`
+
+	// Free-form tag from -label-header, for grouping test runs
+	Label string `json:"label,omitempty"`
+
+	// Output of -transform-template for this payload
+	Transformed string `json:"transformed,omitempty"`
+
+	// Correlation ID from -request-id-header, or generated on receipt
+	RequestID string `json:"request_id,omitempty"`
+
+	// Body exactly as sent; base64-encoded when it isn't valid UTF-8
+	RawBody         string `json:"raw_body"`
+	RawBodyEncoding string `json:"raw_body_encoding,omitempty"`
+
+	// SHA-256 of the canonical payload, set by the store (see hash.go)
+	Hash string `json:"hash,omitempty"`
+
+	// Serialized size counted against -max-bytes; set by WebhookStore
+	size int64
 }
 
+// Controls the order GetAll returns webhooks in. Both modes evict the oldest
+// entry when the store is full.
+type retentionMode string
+
+const (
+	modeLIFO retentionMode = "lifo"
+	modeFIFO retentionMode = "fifo"
+)
+
 type WebhookStore struct {
 	mu       sync.RWMutex
-	webhooks []StoredWebhook
+	webhooks *webhookRing
 	nextID   int
 	maxSize  int
+	maxBytes int64
+	mode     retentionMode
+
+	// Serialized size of everything in webhooks
+	bytes int64
+
+	// IDs of the stored webhooks per event name, ascending. Kept in step
+	// with webhooks by indexLocked and forgetLocked.
+	events map[string][]int
+
+	// Webhooks added since startup, including evicted and deleted ones
+	received int
+
+	// Keep nextID across Clear so IDs are never reused
+	preserveIDs bool
+
+	// Told about webhooks dropped by the caps or the TTL; nil for none
+	onEvict evictFunc
+
+	persistPath string
+
+	// Pending saves for the background writer; nil when saves are
+	// synchronous (see StartAsyncPersist)
+	persistQueue    chan persistedState
+	persistStop     chan struct{}
+	persistDone     chan struct{}
+	persistOverflow overflowPolicy
+	// Numbers saves so the writer never lands an older state after a
+	// newer one
+	persistSeq uint64
+	// A save the block policy is waiting to hand over once ws.mu is
+	// released (see unlock)
+	pendingSave *persistedState
+
+	// Source of Received times; time.Now outside of tests
+	now func() time.Time
+
+	subscribers map[chan StoredWebhook]struct{}
+
+	// Idempotency keys already stored, oldest first in seenOrder
+	seenKeys  map[string]int
+	seenOrder []string
+
+	// Payload hashes stored within dedupWindow, oldest first in
+	// recentOrder; see -dedup-window
+	dedupWindow  time.Duration
+	recentHashes map[string]int
+	recentOrder  []recentHash
 }
 
-var store = &WebhookStore{
-	webhooks: make([]StoredWebhook, 0),
-	nextID:   1,
-	maxSize:  5,
+type server struct {
+	store   Store
+	buckets *bucketRegistry
+	cfg     config
+	metrics *metrics
+	ready   atomic.Bool
+
+	// Set by /admin/pause: webhooks get 503 until /admin/resume
+	paused atomic.Bool
+
+	// Clock for -require-timestamp; time.Now outside of tests
+	now func() time.Time
+
+	forwarder *forwarder
+	schema    *jsonschema.Schema
+	limiter   *rateLimiter
+	failures  *failureInjector
+
+	// Webhook requests being processed, for -max-concurrent
+	concurrency *concurrencyLimiter
+
+	defaultVerifier verifier
+	bucketVerifiers map[string]verifier
+
+	// -access-log, nil when disabled
+	accessLog *rotatingFile
+
+	inFlight atomic.Int64
+}
+
+// Create an in-memory store holding at most maxSize webhooks and, when
+// maxBytes > 0, at most maxBytes of serialized webhooks
+func NewWebhookStore(maxSize int, maxBytes int64, mode retentionMode) *WebhookStore {
+	return &WebhookStore{
+		webhooks: newWebhookRing(maxSize),
+		nextID:   1,
+		maxSize:  maxSize,
+		maxBytes: maxBytes,
+		mode:     mode,
+		now:      time.Now,
+		events:   make(map[string][]int),
+
+		subscribers: make(map[chan StoredWebhook]struct{}),
+		seenKeys:    make(map[string]int),
+
+		recentHashes: make(map[string]int),
+	}
 }
 
 func main() {
-	http.HandleFunc("/webhook", webhookHandler)
-	http.HandleFunc("/webhooks", getWebhooksHandler)
-	http.HandleFunc("/webhooks/", getWebhookByIDHandler)
-	http.HandleFunc("/webhooks/clear", clearWebhooksHandler)
+	cfg, err := loadConfig(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	logger, err := newLogger(os.Stderr, cfg.logLevel, cfg.logFormat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	slog.SetDefault(logger)
+
+	var evictHook *evictionHook
+	if cfg.evictURL != "" {
+		evictHook = newEvictionHook(cfg)
+	}
+
+	var newBucketStore func(bucket string) Store
+	// Buckets already in storage, registered up front
+	var existingBuckets []string
+	if cfg.dbPath != "" {
+		db, err := openSQLite(cfg.dbPath)
+		if err != nil {
+			fatal("failed to open SQLite database", "path", cfg.dbPath, "error", err)
+		}
+		defer db.Close()
+		if existingBuckets, err = sqliteBuckets(db); err != nil {
+			fatal("failed to list SQLite buckets", "path", cfg.dbPath, "error", err)
+		}
+		newBucketStore = func(bucket string) Store {
+			ss := NewSQLiteStore(db, bucket, cfg.maxSizeFor(bucket), cfg.maxBytes, cfg.mode)
+			ss.onEvict = evictHook.forBucket(bucket)
+			return ss
+		}
+	} else {
+		newBucketStore = func(bucket string) Store {
+			ws := NewWebhookStore(cfg.maxSizeFor(bucket), cfg.maxBytes, cfg.mode)
+			ws.preserveIDs = cfg.preserveIDsOnClear
+			ws.dedupWindow = cfg.dedupWindow
+			ws.onEvict = evictHook.forBucket(bucket)
+			return ws
+		}
+	}
+	store := newBucketStore(defaultBucket)
+	if _, ok := store.(idempotentStore); !ok && cfg.dedupHeader != "" {
+		slog.Warn("store does not support deduplication, ignoring -dedup-header")
+	}
+	if _, ok := store.(idempotentStore); !ok && cfg.dedupWindow > 0 {
+		slog.Warn("store does not support deduplication, ignoring -dedup-window")
+	}
+
+	srv := &server{
+		store:   store,
+		buckets: newBucketRegistry(store, newBucketStore, cfg.maxBuckets),
+		cfg:     cfg,
+		metrics: newMetrics(prometheus.DefaultRegisterer, store, cfg.metricsLabelLimit),
+		now:     time.Now,
 
-	fmt.Println("Webhook server listening on :8080...")
-	fmt.Println("Stack-based storage: Maximum 5 webhooks (LIFO)")
-	fmt.Println("Endpoints:")
-	fmt.Println("  POST /webhook - Receive webhooks")
-	fmt.Println("  GET /webhooks - Get all webhooks (most recent first)")
-	fmt.Println("  GET /webhooks/{id} - Get webhook by ID")
+		concurrency: newConcurrencyLimiter(cfg.maxConcurrent),
+	}
+	srv.metrics.registerConcurrency(prometheus.DefaultRegisterer, srv.concurrency)
+	srv.buckets.Preload(existingBuckets...)
+
+	if cfg.schemaPath != "" {
+		schema, err := compileSchema(cfg.schemaPath)
+		if err != nil {
+			fatal("failed to compile JSON schema", "path", cfg.schemaPath, "error", err)
+		}
+		srv.schema = schema
+	}
+
+	srv.defaultVerifier, srv.bucketVerifiers = newVerifiers(cfg)
+
+	if cfg.accessLogPath != "" {
+		accessLog, err := openRotatingFile(cfg.accessLogPath, cfg.accessLogMaxMB<<20)
+		if err != nil {
+			fatal("failed to open access log", "path", cfg.accessLogPath, "error", err)
+		}
+		defer accessLog.Close()
+		srv.accessLog = accessLog
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if cfg.rateLimit > 0 {
+		srv.limiter = newRateLimiter(cfg.rateLimit, cfg.rateBurst)
+		go srv.limiter.cleanupLoop(ctx)
+	}
+
+	if cfg.ttl > 0 {
+		go srv.sweepExpired(ctx, cfg.ttl)
+	}
+
+	if cfg.failRate > 0 {
+		srv.failures = newFailureInjector(cfg.failRate, cfg.failSeed)
+	}
+
+	if len(cfg.forwardTargets) > 0 || len(cfg.forwardMap) > 0 {
+		srv.forwarder = newForwarder(cfg)
+		srv.metrics.registerForwarder(prometheus.DefaultRegisterer, srv.forwarder)
+	}
 
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	if mem, ok := store.(*WebhookStore); ok && cfg.persistPath != "" {
+		if err := mem.Load(cfg.persistPath); err != nil {
+			fatal("failed to load persisted webhooks", "path", cfg.persistPath, "error", err)
+		}
+		if cfg.persistQueue > 0 {
+			mem.StartAsyncPersist(cfg.persistQueue, cfg.persistOverflow)
+		}
+	}
+
+	// Cancelled on shutdown so long-lived streams end instead of holding
+	// Shutdown open until the timeout
+	baseCtx, cancelBase := context.WithCancel(context.Background())
+	httpServer := &http.Server{
+		Addr:         cfg.addr,
+		Handler:      srv.trackInFlight(srv.routes()),
+		BaseContext:  func(net.Listener) context.Context { return baseCtx },
+		ReadTimeout:  cfg.readTimeout,
+		WriteTimeout: cfg.writeTimeout,
+		IdleTimeout:  cfg.idleTimeout,
+	}
+	httpServer.RegisterOnShutdown(cancelBase)
+
+	useTLS := cfg.tlsCert != ""
+	if useTLS {
+		tlsConfig, err := loadTLSConfig(cfg.tlsCert, cfg.tlsKey, cfg.tlsClientCA)
+		if err != nil {
+			fatal("failed to load TLS configuration", "cert", cfg.tlsCert, "key", cfg.tlsKey, "client_ca", cfg.tlsClientCA, "error", err)
+		}
+		httpServer.TLSConfig = tlsConfig
+	}
+
+	slog.Info("webhook server listening",
+		"addr", cfg.addr,
+		"tls", useTLS,
+		"tls_redirect_addr", cfg.tlsRedirectAddr,
+		"tls_client_ca", cfg.tlsClientCA,
+		"base_path", cfg.basePath,
+		"max_size", cfg.maxSize,
+		"max_bytes", cfg.maxBytes,
+		"bucket_config", cfg.bucketSizes,
+		"max_buckets", cfg.maxBuckets,
+		"mode", cfg.mode,
+		"signature_verification", cfg.secret != "",
+		"signature_secrets", len(splitList(cfg.secret)),
+		"bucket_secrets", slices.Sorted(maps.Keys(cfg.bucketSecrets)),
+		"stripe_verification", cfg.stripeSecret != "",
+		"stripe_buckets", cfg.stripeBuckets,
+		"standard_webhooks_verification", cfg.standardSecret != "",
+		"standard_webhooks_buckets", cfg.standardBuckets,
+		"persist", cfg.persistPath,
+		"preserve_ids_on_clear", cfg.preserveIDsOnClear,
+		"persist_queue", cfg.persistQueue,
+		"persist_overflow", cfg.persistOverflow,
+		"db", cfg.dbPath,
+		"forward", cfg.forwardTargets,
+		"forward_map", cfg.forwardMap,
+		"forward_concurrency", cfg.forwardConcurrency,
+		"forward_overflow", cfg.forwardOverflow,
+		"evict_url", cfg.evictURL,
+		"max_body", cfg.maxBody,
+		"max_import_body", cfg.maxImportBody,
+		"schema", cfg.schemaPath,
+		"rate", cfg.rateLimit,
+		"burst", cfg.rateBurst,
+		"max_concurrent", cfg.maxConcurrent,
+		"allow_cidr", cfg.allowCIDRs,
+		"trusted_proxies", cfg.trustedProxies,
+		"cors_origin", cfg.corsOrigins,
+		"read_auth", cfg.readUser != "",
+		"admin_token", cfg.adminToken != "",
+		"ttl", cfg.ttl,
+		"dedup_header", cfg.dedupHeader,
+		"dedup_window", cfg.dedupWindow,
+		"label_header", cfg.labelHeader,
+		"request_id_header", cfg.requestIDHeader,
+		"expand_arrays", cfg.expandArrays,
+		"max_batch", cfg.maxBatch,
+		"methods", cfg.methods,
+		"ack_status", cfg.ackStatus,
+		"custom_ack_body", cfg.ackBody != "",
+		"ack_content_type", cfg.ackContentType,
+		"replay_allow_hosts", cfg.replayAllowHosts,
+		"redact", cfg.redactKeys,
+		"summary_fields", cfg.summaryFields,
+		"transform_template", cfg.transformTemplate != nil,
+		"response_delay", cfg.responseDelay,
+		"debug", cfg.debug,
+		"fail_rate", cfg.failRate,
+		"reject_when", cfg.rejectRules,
+		"reject_status", cfg.rejectStatus,
+		"redirect_events", cfg.redirectEvents,
+		"redirect_status", cfg.redirectStatus,
+		"require_timestamp", cfg.requireTimestamp,
+		"timestamp_skew", cfg.timestampSkew,
+		"tz", cfg.location.String(),
+		"metrics_label_limit", cfg.metricsLabelLimit,
+		"access_log", cfg.accessLogPath,
+		"access_log_max_mb", cfg.accessLogMaxMB,
+		"read_timeout", cfg.readTimeout,
+		"write_timeout", cfg.writeTimeout,
+		"idle_timeout", cfg.idleTimeout,
+		"handler_timeout", cfg.handlerTimeout,
+	)
+	for _, e := range srv.endpoints() {
+		slog.Info("endpoint", "route", e.route, "description", e.description)
+	}
+
+	serveErr := make(chan error, 2)
+	go func() {
+		if useTLS {
+			// Certificates come from TLSConfig
+			serveErr <- httpServer.ListenAndServeTLS("", "")
+		} else {
+			serveErr <- httpServer.ListenAndServe()
+		}
+	}()
+
+	var redirectServer *http.Server
+	if cfg.tlsRedirectAddr != "" {
+		redirectServer = newRedirectServer(cfg.tlsRedirectAddr, cfg.addr)
+		go func() {
+			serveErr <- redirectServer.ListenAndServe()
+		}()
+	}
+	srv.ready.Store(true)
+
+	select {
+	case err := <-serveErr:
+		fatal("server failed", "error", err)
+	case <-ctx.Done():
+	}
+
+	if redirectServer != nil {
+		// Redirects finish immediately, so there's nothing to drain
+		redirectServer.Close()
+	}
+	srv.shutdown(httpServer)
 }
 
-// Store incoming webhooks (stack behavior - LIFO with max size)
-func (ws *WebhookStore) Add(payload interface{}) int {
-	ws.mu.Lock()
-	defer ws.mu.Unlock()
+func (s *server) routes() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/healthz", s.healthzHandler)
+	mux.HandleFunc("/readyz", s.readyzHandler)
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/version", s.versionHandler)
+	mux.HandleFunc("/openapi.json", s.openAPIHandler)
+
+	mux.HandleFunc("/webhook", s.withRequestID(s.withAccessLog(s.allowSource(s.rejectWhilePaused(s.rateLimit(s.limitConcurrent(s.webhookHandler)))))))
+	mux.HandleFunc("/webhook/", s.withRequestID(s.withAccessLog(s.allowSource(s.rejectWhilePaused(s.rateLimit(s.limitConcurrent(s.bucketWebhookHandler)))))))
+	mux.HandleFunc("/webhook/validate", s.withRequestID(s.withAccessLog(s.allowSource(s.rateLimit(s.limitConcurrent(s.validateWebhookHandler))))))
+	mux.HandleFunc("/buckets", s.cors(s.readAuth(s.listBucketsHandler)))
+	mux.HandleFunc("/webhooks", s.cors(s.readAuth(s.getWebhooksHandler)))
+	mux.HandleFunc("/webhooks/", s.cors(s.readAuth(s.webhookByIDHandler)))
+	mux.HandleFunc("/webhooks/clear", s.adminAuth(s.clearWebhooksHandler))
+	mux.HandleFunc("/webhooks/stream", s.cors(s.readAuth(s.streamWebhooksHandler)))
+	mux.HandleFunc("/ws", s.readAuth(s.wsHandler))
+	mux.HandleFunc("/webhooks/search", s.cors(s.readAuth(s.searchWebhooksHandler)))
+	mux.HandleFunc("/webhooks/export", s.cors(s.readAuth(s.exportWebhooksHandler)))
+	mux.HandleFunc("/webhooks/export.csv", s.cors(s.readAuth(s.exportCSVHandler)))
+	mux.HandleFunc("/webhooks/stats", s.cors(s.readAuth(s.statsHandler)))
+	mux.HandleFunc("/webhooks/latest", s.cors(s.readAuth(s.latestWebhookHandler)))
+	mux.HandleFunc("/webhooks/by-day", s.cors(s.readAuth(s.webhooksByDayHandler)))
+	mux.HandleFunc("/webhooks/import", s.adminAuth(s.importWebhooksHandler))
+	mux.HandleFunc("/config/max-size", s.adminAuth(s.maxSizeHandler))
+	mux.HandleFunc("/admin/snapshot", s.adminAuth(s.snapshotHandler))
+	mux.HandleFunc("/admin/restore", s.adminAuth(s.restoreHandler))
+	mux.HandleFunc("/admin/pause", s.adminAuth(s.pauseHandler))
+	mux.HandleFunc("/admin/resume", s.adminAuth(s.resumeHandler))
+	mux.HandleFunc("/{$}", s.readAuth(s.dashboardHandler))
+	// More specific patterns win, so this only sees unmatched paths
+	mux.HandleFunc("/", s.notFoundHandler)
 
-	storedWebhook := StoredWebhook{
-		ID:       ws.nextID,
-		Payload:  payload,
-		Received: time.Now(),
+	handler := s.withTimeout(mux)
+	if s.cfg.basePath == "" {
+		return handler
 	}
+	// Handlers see paths relative to the base, so their own prefix
+	// handling is unchanged
+	root := http.NewServeMux()
+	root.Handle(s.cfg.basePath+"/", http.StripPrefix(s.cfg.basePath, handler))
+	root.HandleFunc("/", s.notFoundHandler)
+	return root
+}
+
+// Store incoming webhooks (stack behavior - LIFO with max size). The ID and
+// received time are assigned by the store, which returns the stored copy.
+func (ws *WebhookStore) Add(webhook StoredWebhook) StoredWebhook {
+	ws.mu.Lock()
+	defer ws.unlock()
+
+	stored, _ := ws.addLocked(webhook, false)
+	return stored
+}
+
+// Store webhook and save the stack. A failed save is logged, or with
+// durable set the webhook is taken back out and the error returned so the
+// sender can be asked to retry; anything it displaced stays evicted.
+// Callers must hold ws.mu.
+func (ws *WebhookStore) addLocked(webhook StoredWebhook, durable bool) (StoredWebhook, error) {
+	webhook.ID = ws.nextID
+	webhook.Received = ws.now()
+	webhook.Hash = payloadHash(webhook)
+	webhook.size = webhookSize(webhook)
 
-	ws.webhooks = append(ws.webhooks, storedWebhook)
-	currentID := ws.nextID
+	if evicted, full := ws.webhooks.Push(webhook); full {
+		ws.forgetLocked(evicted)
+		ws.evictedLocked(evictOverflow, evicted)
+	}
+	ws.indexLocked(webhook)
 	ws.nextID++
+	ws.received++
+
+	ws.evictLocked()
+
+	if err := ws.saveLocked(); err != nil {
+		if durable {
+			ws.forgetLocked(ws.webhooks.PopNewest())
+			ws.nextID--
+			ws.received--
+			return StoredWebhook{}, err
+		}
+		slog.Error("failed to persist webhooks", "error", err)
+	}
+
+	ws.broadcastLocked(webhook)
+
+	return webhook, nil
+}
 
-	if len(ws.webhooks) > ws.maxSize {
-		ws.webhooks = ws.webhooks[1:]
+// Evict the oldest webhooks until the byte cap holds; the ring itself
+// enforces maxSize. The newest webhook is always kept, even when it alone
+// exceeds maxBytes. Callers must hold ws.mu.
+func (ws *WebhookStore) evictLocked() {
+	for ws.maxBytes > 0 && ws.bytes > ws.maxBytes && ws.webhooks.Len() > 1 {
+		evicted := ws.webhooks.PopOldest()
+		ws.forgetLocked(evicted)
+		ws.evictedLocked(evictOverflow, evicted)
 	}
+}
 
-	return currentID
+// Serialized size of webhook, as returned by the API
+func webhookSize(webhook StoredWebhook) int64 {
+	data, err := json.Marshal(webhook)
+	if err != nil {
+		return 0
+	}
+	return int64(len(data))
 }
 
+// Return stored webhooks ordered by arrival (which is also ID order):
+// newest-first in LIFO mode, oldest-first in FIFO mode
 func (ws *WebhookStore) GetAll() []StoredWebhook {
 	ws.mu.RLock()
 	defer ws.mu.RUnlock()
 
-	result := make([]StoredWebhook, len(ws.webhooks))
-	for i, j := 0, len(ws.webhooks)-1; i < len(ws.webhooks); i, j = i+1, j-1 {
-		result[i] = ws.webhooks[j]
+	return ws.orderedLocked()
+}
+
+// Copy the stack in GetAll order. Callers must hold ws.mu.
+func (ws *WebhookStore) orderedLocked() []StoredWebhook {
+	if ws.mode == modeFIFO {
+		return ws.webhooks.Slice()
+	}
+	n := ws.webhooks.Len()
+	result := make([]StoredWebhook, n)
+	for i := range result {
+		result[i] = ws.webhooks.At(n - 1 - i)
 	}
 	return result
 }
 
+func (ws *WebhookStore) GetAllWithCapacity() (webhooks []StoredWebhook, nextID, maxSize int) {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	return ws.orderedLocked(), ws.nextID, ws.maxSize
+}
+
+func (ws *WebhookStore) MaxSize() int {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	return ws.maxSize
+}
+
+func (ws *WebhookStore) Len() int {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	return ws.webhooks.Len()
+}
+
+func (ws *WebhookStore) Received() int {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	return ws.received
+}
+
+func (ws *WebhookStore) NextID() int {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	return ws.nextID
+}
+
 func (ws *WebhookStore) GetByID(id int) (StoredWebhook, bool) {
 	ws.mu.RLock()
 	defer ws.mu.RUnlock()
 
-	for _, webhook := range ws.webhooks {
-		if webhook.ID == id {
+	return ws.findLocked(id)
+}
+
+// Callers must hold ws.mu
+func (ws *WebhookStore) findLocked(id int) (StoredWebhook, bool) {
+	for i := 0; i < ws.webhooks.Len(); i++ {
+		if webhook := ws.webhooks.At(i); webhook.ID == id {
 			return webhook, true
 		}
 	}
 	return StoredWebhook{}, false
 }
 
+// Remove a single webhook, keeping the remaining ones in order
+func (ws *WebhookStore) DeleteByID(id int) bool {
+	ws.mu.Lock()
+	defer ws.unlock()
+
+	removed := ws.webhooks.Filter(func(webhook StoredWebhook) bool {
+		return webhook.ID != id
+	})
+	if len(removed) == 0 {
+		return false
+	}
+	ws.forgetLocked(removed[0])
+	if err := ws.saveLocked(); err != nil {
+		slog.Error("failed to persist webhooks", "error", err)
+	}
+	return true
+}
+
+// Return the body as a string, falling back to base64 for binary data
+func encodeRawBody(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
 func getStringFromPayload(payload interface{}, key string) string {
+	if form, ok := payload.(map[string][]string); ok {
+		if values := form[key]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
 	if payloadMap, ok := payload.(map[string]interface{}); ok {
 		if value, exists := payloadMap[key]; exists {
 			if strValue, ok := value.(string); ok {
@@ -117,100 +671,481 @@ func getInt64FromPayload(payload interface{}, key string) int64 {
 	return 0
 }
 
+// Remove every webhook, returning how many there were. IDs restart at 1
+// unless preserveIDs is set. Restarting keeps IDs small and matches a fresh
+// start, but a client still holding an old ID will then fetch or delete
+// whichever new webhook reused it. Preserving IDs rules that out, at the
+// cost of IDs that only ever grow.
 func (ws *WebhookStore) Clear() int {
 	ws.mu.Lock()
-	defer ws.mu.Unlock()
+	defer ws.unlock()
 
-	count := len(ws.webhooks)
-	ws.webhooks = make([]StoredWebhook, 0)
-	ws.nextID = 1
+	count := ws.webhooks.Len()
+	ws.webhooks.Reset()
+	ws.bytes = 0
+	ws.events = make(map[string][]int)
+	if !ws.preserveIDs {
+		ws.nextID = 1
+	}
+	ws.resetDedupLocked()
+
+	if err := ws.saveLocked(); err != nil {
+		slog.Error("failed to persist webhooks", "error", err)
+	}
 
 	return count
 }
 
-func webhookHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+func (s *server) webhookHandler(w http.ResponseWriter, r *http.Request) {
+	s.receiveWebhook(w, r, defaultBucket)
+}
+
+// Whether a webhook carries no data at all. Data sent only in the query
+// string (see -methods) is still accepted.
+func emptyRequest(body []byte, rawQuery string) bool {
+	return len(body) == 0 && rawQuery == ""
+}
+
+// Verify, decode and store a webhook in the given bucket
+func (s *server) receiveWebhook(w http.ResponseWriter, r *http.Request, bucket string) {
+	if !slices.Contains(s.cfg.methods, r.Method) {
+		w.Header().Set("Allow", strings.Join(s.cfg.methods, ", "))
+		s.reject(w, r, rejectMethod, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	var payload interface{}
-	err := json.NewDecoder(r.Body).Decode(&payload)
+	if !s.checkContentType(w, r) {
+		return
+	}
+
+	body, ok := s.readBody(w, r)
+	if !ok {
+		return
+	}
+	if emptyRequest(body, r.URL.RawQuery) {
+		s.reject(w, r, rejectEmptyBody, "empty body", http.StatusBadRequest)
+		return
+	}
+
+	if s.failures != nil && s.failures.Fail() {
+		slog.Info("injecting failure", "bucket", bucket, "fail_rate", s.cfg.failRate, "remote_addr", s.clientIP(r))
+		s.reject(w, r, rejectInjected, "Injected failure", http.StatusInternalServerError)
+		return
+	}
+
+	verified := false
+	if v := s.verifierFor(bucket); v != nil {
+		if err := v.Verify(r.Header, body); err != nil {
+			slog.Warn("signature verification failed", "bucket", bucket, "error", err)
+			s.reject(w, r, rejectSignature, "Invalid signature", http.StatusUnauthorized)
+			return
+		}
+		verified = true
+	}
+
+	// Bodies that can't be decoded are still stored; only the decoded
+	// payload is dropped
+	payload, err := decodePayload(r.Header.Get("Content-Type"), body, s.maxElements())
+	if errors.Is(err, errTooManyElements) {
+		s.reject(w, r, rejectTooLarge, fmt.Sprintf("Batch exceeds %d elements", s.cfg.maxBatch), http.StatusRequestEntityTooLarge)
+		return
+	}
 	if err != nil {
-		http.Error(w, "Bad request", http.StatusBadRequest)
+		slog.Debug("could not decode body, storing raw body only", "error", err)
+		payload = nil
+	}
+
+	if elements, ok := payload.([]interface{}); ok && s.cfg.expandArrays {
+		if s.applyRejectRules(w, r, bucket, elements...) || !s.requireTimestamps(w, r, elements...) {
+			return
+		}
+		s.receiveBatch(w, r, bucket, body, elements, verified)
+		return
+	}
+	if s.applyRejectRules(w, r, bucket, payload) || !s.requireTimestamps(w, r, payload) {
 		return
 	}
 
-	assignedID := store.Add(payload)
+	if s.schema != nil {
+		if failures := validatePayload(s.schema, payload); failures != nil {
+			s.rejectSchema(w, r, failures)
+			return
+		}
+	}
 
-	event := getStringFromPayload(payload, "event")
-	timestamp := getInt64FromPayload(payload, "timestamp")
+	webhook := s.newWebhook(r, body, payload, verified)
+	event, deliveryID, remoteAddr := webhook.Event, webhook.DeliveryID, webhook.RemoteAddr
+	noteAccessEvent(r, event)
 
-	fmt.Printf("Stored webhook with ID: %d\n", assignedID)
-	if event != "" {
-		fmt.Printf("Event: %s\n", event)
+	idempotencyKey := s.idempotencyKey(r)
+	store, ok := s.bucketStore(w, r, bucket)
+	if !ok {
+		return
+	}
+	stored, duplicate, err := addWebhook(store, webhook, idempotencyKey)
+	if err != nil {
+		s.rejectStorageError(w, r, bucket, err)
+		return
 	}
-	if timestamp != 0 {
-		fmt.Printf("Timestamp: %d\n", timestamp)
+	assignedID := stored.ID
+	if duplicate {
+		slog.Info("duplicate webhook ignored",
+			"webhook_id", assignedID,
+			"bucket", bucket,
+			"idempotency_key", idempotencyKey,
+			"remote_addr", remoteAddr,
+			"request_id", stored.RequestID,
+		)
+		s.writeAck(w, r, map[string]interface{}{
+			"message":   "Duplicate webhook ignored",
+			"id":        assignedID,
+			"duplicate": true,
+		})
+		return
 	}
-	fmt.Printf("Full payload: %+v\n", payload)
+	s.metrics.countReceived(bucket, event)
+
+	if s.forwarder != nil {
+		s.forwarder.Forward(body, r.Header.Get("Content-Type"), event)
+	}
+
+	timestamp := getInt64FromPayload(payload, "timestamp")
+	summary := summarize(stored.Payload, s.cfg.summaryFields)
+
+	slog.Info("webhook stored",
+		"webhook_id", assignedID,
+		"bucket", bucket,
+		"event", event,
+		"delivery_id", deliveryID,
+		"timestamp", timestamp,
+		"remote_addr", remoteAddr,
+		"request_id", stored.RequestID,
+		"status", http.StatusOK,
+		summaryAttr(summary, s.cfg.summaryFields),
+	)
+	slog.Debug("full payload", "webhook_id", assignedID, "payload", payload)
 
-	w.WriteHeader(http.StatusOK)
 	response := map[string]interface{}{
 		"message": "Webhook received and stored successfully",
 		"id":      assignedID,
+		"webhook": stored,
 	}
-	json.NewEncoder(w).Encode(response)
+	if summary != nil {
+		response["summary"] = summary
+	}
+	if s.redirectAck(w, r, event, assignedID) {
+		return
+	}
+	s.writeAck(w, r, response)
+}
+
+// Array length limit for decodePayload; only batches that will be expanded
+// are limited
+func (s *server) maxElements() int {
+	if !s.cfg.expandArrays {
+		return 0
+	}
+	return s.cfg.maxBatch
+}
+
+// Reject requests whose Content-Type decodePayload can't handle with 415
+func (s *server) checkContentType(w http.ResponseWriter, r *http.Request) bool {
+	if _, err := contentFormat(r.Header.Get("Content-Type")); err != nil {
+		s.reject(w, r, rejectUnsupportedType,
+			fmt.Sprintf("%v; accepted types: %s", err, strings.Join(acceptedContentTypes, ", ")),
+			http.StatusUnsupportedMediaType)
+		return false
+	}
+	return true
+}
+
+// Read and decompress the request body, enforcing -max-body. Rejects the
+// request and returns false on failure.
+func (s *server) readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	if s.cfg.maxBody > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, s.cfg.maxBody)
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			s.reject(w, r, rejectTooLarge, fmt.Sprintf("Request body exceeds %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+			return nil, false
+		}
+		s.reject(w, r, rejectBadBody, "Bad request", http.StatusBadRequest)
+		return nil, false
+	}
+
+	body, err = decodeBody(body, r.Header.Get("Content-Encoding"), s.cfg.maxBody)
+	if errors.Is(err, errDecompressedTooLarge) {
+		s.reject(w, r, rejectTooLarge, fmt.Sprintf("Decompressed body exceeds %d bytes", s.cfg.maxBody), http.StatusRequestEntityTooLarge)
+		return nil, false
+	}
+	if err != nil {
+		s.reject(w, r, rejectBadBody, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	s.metrics.bodySize.Observe(float64(len(body)))
+	return body, true
+}
+
+// Build the webhook to store from the request and its decoded body
+func (s *server) newWebhook(r *http.Request, body []byte, payload interface{}, verified bool) StoredWebhook {
+	event, deliveryID := extractMetadata(r.Header, payload)
+	payload, body = redactPayload(payload, body, s.cfg.redactKeys)
+	rawBody, rawEncoding := encodeRawBody(body)
+	return StoredWebhook{
+		Payload:         payload,
+		Headers:         redactHeaders(r.Header, s.cfg.redactHeaders),
+		RawBody:         rawBody,
+		RawBodyEncoding: rawEncoding,
+		RemoteAddr:      s.clientIP(r),
+		UserAgent:       r.UserAgent(),
+		ClientCN:        clientCN(r),
+		Method:          r.Method,
+		Query:           r.URL.Query(),
+		Event:           event,
+		DeliveryID:      deliveryID,
+		EventTime:       eventTime(payload),
+		Verified:        verified,
+		Label:           s.label(r),
+		RequestID:       requestIDFrom(r),
+		Transformed:     s.transform(payload),
+	}
+}
+
+// Interpret the payload's "timestamp" field as Unix seconds, or
+// milliseconds when it is too large to be seconds
+func eventTime(payload interface{}) *time.Time {
+	ts := getInt64FromPayload(payload, "timestamp")
+	if ts <= 0 {
+		return nil
+	}
+	t := unixTimestamp(ts)
+	return &t
+}
+
+// Label sent with the request, or "" when -label-header is off
+func (s *server) label(r *http.Request) string {
+	if s.cfg.labelHeader == "" {
+		return ""
+	}
+	return strings.TrimSpace(r.Header.Get(s.cfg.labelHeader))
+}
+
+// Idempotency key sent with the request, or "" when -dedup-header is off
+func (s *server) idempotencyKey(r *http.Request) string {
+	if s.cfg.dedupHeader == "" {
+		return ""
+	}
+	return r.Header.Get(s.cfg.dedupHeader)
+}
+
+// Add webhook to store, deduplicating on key when the store supports it.
+// Reports whether webhook was a duplicate of an already stored one, and
+// any error writing it to durable storage, in which case it wasn't stored.
+func addWebhook(store Store, webhook StoredWebhook, key string) (StoredWebhook, bool, error) {
+	if durable, ok := store.(durableStore); ok {
+		return durable.AddDurable(webhook, key)
+	}
+	if idem, ok := store.(idempotentStore); ok {
+		stored, duplicate := idem.AddIdempotent(webhook, key)
+		return stored, duplicate, nil
+	}
+	return store.Add(webhook), false, nil
 }
 
-func getWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+// Retry-After sent when a webhook couldn't be written to storage
+const storageRetryAfter = 5
+
+// Answer a webhook whose storage write failed with 503, so the sender
+// retries it later
+func (s *server) rejectStorageError(w http.ResponseWriter, r *http.Request, bucket string, err error) {
+	slog.Error("failed to persist webhook", "bucket", bucket, "request_id", requestIDFrom(r), "error", err)
+	w.Header().Set("Retry-After", strconv.Itoa(storageRetryAfter))
+	s.reject(w, r, rejectStorage, "Failed to store webhook, retry later", http.StatusServiceUnavailable)
+}
+
+func (s *server) rejectSchema(w http.ResponseWriter, r *http.Request, failures []schemaError) {
+	s.metrics.rejected.WithLabelValues(rejectSchema).Inc()
+	slog.Warn("webhook rejected",
+		"reason", rejectSchema,
+		"status", http.StatusUnprocessableEntity,
+		"remote_addr", s.clientIP(r),
+		"failures", len(failures),
+	)
+	writeSchemaErrors(w, failures)
+}
+
+// Count, log and answer a rejected webhook request
+func (s *server) reject(w http.ResponseWriter, r *http.Request, reason, message string, status int) {
+	s.metrics.rejected.WithLabelValues(reason).Inc()
+	slog.Warn("webhook rejected",
+		"reason", reason,
+		"status", status,
+		"remote_addr", s.clientIP(r),
+		"request_id", requestIDFrom(r),
+	)
+	writeError(w, message, status)
+}
+
+func (s *server) getWebhooksHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	webhooks := store.GetAll()
+	s.listWebhooks(w, r, s.store)
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"count":    len(webhooks),
-		"webhooks": webhooks,
-	})
+// List store's webhooks as JSON, NDJSON or CSV depending on Accept. The
+// filters, order and pagination apply whichever format is chosen.
+func (s *server) listWebhooks(w http.ResponseWriter, r *http.Request, store Store) {
+	query := r.URL.Query()
+
+	w.Header().Add("Vary", "Accept")
+	format, ok := negotiateListFormat(r)
+	if !ok {
+		writeError(w, "Not acceptable, expected application/json, application/x-ndjson or text/csv", http.StatusNotAcceptable)
+		return
+	}
+
+	var all []StoredWebhook
+	response := map[string]interface{}{}
+	setCapacity := func(stored, nextID, maxSize int) {
+		response["max_size"] = maxSize
+		response["next_id"] = nextID
+		response["remaining"] = max(maxSize-stored, 0)
+	}
+	indexed, isIndexed := store.(eventIndexedStore)
+	if event := query.Get("event"); event != "" && isIndexed {
+		// filterList still runs, but only over this event's webhooks
+		var stored, nextID, maxSize int
+		all, stored, nextID, maxSize = indexed.GetByEventWithCapacity(event, query.Get("match") == "prefix")
+		setCapacity(stored, nextID, maxSize)
+	} else if c, ok := store.(capacityStore); ok {
+		var nextID, maxSize int
+		all, nextID, maxSize = c.GetAllWithCapacity()
+		setCapacity(len(all), nextID, maxSize)
+	} else {
+		all = store.GetAll()
+	}
+
+	ascending, ok := s.listOrder(r)
+	if !ok {
+		writeError(w, "Invalid order, expected asc or desc", http.StatusBadRequest)
+		return
+	}
+
+	webhooks, err := filterList(all, query, s.now())
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Stores list in -mode order, so the other order is just a reversal
+	if ascending != (s.cfg.mode == modeFIFO) {
+		slices.Reverse(webhooks)
+	}
+
+	page, err := paginate(webhooks, query)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch format {
+	case listNDJSON:
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(w)
+		for _, webhook := range page {
+			if err := enc.Encode(webhook); err != nil {
+				return
+			}
+		}
+	case listCSV:
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		writeCSV(w, page)
+	default:
+		response["count"] = len(webhooks)
+		response["returned"] = len(page)
+		response["webhooks"] = page
+		writeJSON(w, r, response)
+	}
 }
 
-func getWebhookByIDHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+// /webhooks/{id}[/{sub-resource}] and /webhooks/{bucket}. Only the first
+// segment is the ID, so a trailing slash doesn't change the meaning.
+func (s *server) webhookByIDHandler(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/webhooks/"), "/")
+	idStr, sub, _ := strings.Cut(rest, "/")
+
+	switch sub {
+	case "":
+	case "replay":
+		s.replayWebhookHandler(w, r, idStr)
+		return
+	default:
+		writeError(w, "Not found", http.StatusNotFound)
 		return
 	}
 
-	path := r.URL.Path
-	if len(path) < 10 {
-		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+	if r.Method != http.MethodGet && r.Method != http.MethodDelete {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if r.Method == http.MethodGet && validBucketName(idStr) {
+		store, ok := s.buckets.Lookup(idStr)
+		if !ok {
+			writeError(w, "Bucket not found", http.StatusNotFound)
+			return
+		}
+		s.listWebhooks(w, r, store)
 		return
 	}
 
-	idStr := path[10:]
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		writeError(w, "Invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		if s.requireAdmin(w, r) {
+			s.deleteWebhook(w, id)
+		}
 		return
 	}
 
-	webhook, found := store.GetByID(id)
+	webhook, found := s.store.GetByID(id)
 	if !found {
-		http.Error(w, "Webhook not found", http.StatusNotFound)
+		writeError(w, "Webhook not found", http.StatusNotFound)
 		return
 	}
 
+	writeJSON(w, r, webhook)
+}
+
+func (s *server) deleteWebhook(w http.ResponseWriter, id int) {
+	if !s.store.DeleteByID(id) {
+		writeError(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	slog.Info("webhook deleted", "webhook_id", id)
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(webhook)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Webhook deleted successfully",
+		"id":      id,
+	})
 }
 
-func clearWebhooksHandler(w http.ResponseWriter, r *http.Request) {
-	clearedCount := store.Clear()
+func (s *server) clearWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	clearedCount := s.store.Clear()
 
-	fmt.Printf("Cleared all webhooks. Total cleared: %d\n", clearedCount)
+	slog.Info("webhooks cleared", "cleared_count", clearedCount)
 
 	w.Header().Set("Content-Type", "application/json")
 	response := map[string]interface{}{
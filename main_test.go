@@ -0,0 +1,379 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestMain(m *testing.M) {
+	slog.SetDefault(slog.New(slog.DiscardHandler))
+	os.Exit(m.Run())
+}
+
+// Build an in-memory server from command-line flags the way main does,
+// with metrics on a private registry so each test can have its own
+func newTestServer(t *testing.T, args ...string) *server {
+	t.Helper()
+
+	cfg, err := loadConfig(args)
+	if err != nil {
+		t.Fatalf("loadConfig(%q): %v", args, err)
+	}
+	newBucketStore := func(bucket string) Store {
+		ws := NewWebhookStore(cfg.maxSizeFor(bucket), cfg.maxBytes, cfg.mode)
+		ws.preserveIDs = cfg.preserveIDsOnClear
+		ws.dedupWindow = cfg.dedupWindow
+		return ws
+	}
+	store := newBucketStore(defaultBucket)
+	srv := &server{
+		store:       store,
+		buckets:     newBucketRegistry(store, newBucketStore, cfg.maxBuckets),
+		cfg:         cfg,
+		metrics:     newMetrics(prometheus.NewRegistry(), store, cfg.metricsLabelLimit),
+		concurrency: newConcurrencyLimiter(cfg.maxConcurrent),
+		now:         time.Now,
+	}
+	if cfg.schemaPath != "" {
+		if srv.schema, err = compileSchema(cfg.schemaPath); err != nil {
+			t.Fatalf("compileSchema: %v", err)
+		}
+	}
+	srv.defaultVerifier, srv.bucketVerifiers = newVerifiers(cfg)
+	return srv
+}
+
+// Settable time source for WebhookStore.now and server.now
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+// Serve one request through the server's full route table
+func serve(srv *server, method, target, body string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, req)
+	return rec
+}
+
+// POST a JSON body to path
+func postJSON(srv *server, path, body string) *httptest.ResponseRecorder {
+	return serve(srv, http.MethodPost, path, body, http.Header{"Content-Type": {"application/json"}})
+}
+
+// Store each body in the default bucket, failing the test on a non-200
+func mustPost(t *testing.T, srv *server, bodies ...string) {
+	t.Helper()
+	for _, body := range bodies {
+		if rec := postJSON(srv, "/webhook", body); rec.Code != http.StatusOK {
+			t.Fatalf("POST /webhook %s: status %d, body %s", body, rec.Code, rec.Body)
+		}
+	}
+}
+
+// Decode a JSON response body into v
+func decodeResponse(t *testing.T, rec *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("decoding response %q: %v", rec.Body, err)
+	}
+}
+
+// Fields of the POST /webhook acknowledgement the tests look at
+type ackResponse struct {
+	ID        int   `json:"id"`
+	IDs       []int `json:"ids"`
+	Count     int   `json:"count"`
+	Duplicate bool  `json:"duplicate"`
+}
+
+// IDs of the default bucket as GET /webhooks lists them
+func listedIDs(t *testing.T, srv *server) []int {
+	t.Helper()
+	return listedIDsAt(t, srv, "/webhooks")
+}
+
+// IDs listed by a GET of target, a /webhooks URL with filters
+func listedIDsAt(t *testing.T, srv *server, target string) []int {
+	t.Helper()
+	rec := serve(srv, http.MethodGet, target, "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s: status %d, body %s", target, rec.Code, rec.Body)
+	}
+	var list struct {
+		Webhooks []StoredWebhook `json:"webhooks"`
+	}
+	decodeResponse(t, rec, &list)
+	ids := make([]int, len(list.Webhooks))
+	for i, webhook := range list.Webhooks {
+		ids[i] = webhook.ID
+	}
+	return ids
+}
+
+func TestDeleteWebhookByID(t *testing.T) {
+	tests := []struct {
+		name   string
+		id     string
+		status int
+		remain []int
+	}{
+		{"top", "5", http.StatusOK, []int{4, 3, 2, 1}},
+		{"bottom", "1", http.StatusOK, []int{5, 4, 3, 2}},
+		{"middle", "3", http.StatusOK, []int{5, 4, 2, 1}},
+		{"missing", "9", http.StatusNotFound, []int{5, 4, 3, 2, 1}},
+		{"not a number", "abc", http.StatusBadRequest, []int{5, 4, 3, 2, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t)
+			mustPost(t, srv, `{"n":1}`, `{"n":2}`, `{"n":3}`, `{"n":4}`, `{"n":5}`)
+
+			rec := serve(srv, http.MethodDelete, "/webhooks/"+tt.id, "", nil)
+			if rec.Code != tt.status {
+				t.Fatalf("DELETE /webhooks/%s: status %d, want %d", tt.id, rec.Code, tt.status)
+			}
+			if tt.status == http.StatusOK {
+				var resp struct {
+					ID int `json:"id"`
+				}
+				decodeResponse(t, rec, &resp)
+				if want, _ := strconv.Atoi(tt.id); resp.ID != want {
+					t.Errorf("response id = %d, want %d", resp.ID, want)
+				}
+			}
+			if got := listedIDs(t, srv); !slices.Equal(got, tt.remain) {
+				t.Errorf("remaining ids = %v, want %v", got, tt.remain)
+			}
+		})
+	}
+}
+
+func TestRetentionModeOrder(t *testing.T) {
+	tests := []struct {
+		mode string
+		want []int
+	}{
+		{"lifo", []int{5, 4, 3}},
+		{"fifo", []int{3, 4, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			srv := newTestServer(t, "-max", "3", "-mode", tt.mode)
+			mustPost(t, srv, `{"n":1}`, `{"n":2}`, `{"n":3}`, `{"n":4}`, `{"n":5}`)
+
+			if got := listedIDs(t, srv); !slices.Equal(got, tt.want) {
+				t.Errorf("ids after overflow = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// JSON object of exactly n bytes
+func jsonOfSize(n int) string {
+	return `{"p":"` + strings.Repeat("x", n-8) + `"}`
+}
+
+func TestMaxBodySize(t *testing.T) {
+	tests := []struct {
+		name   string
+		size   int
+		status int
+	}{
+		{"under the limit", 63, http.StatusOK},
+		{"at the limit", 64, http.StatusOK},
+		{"over the limit", 65, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t, "-max-body", "64")
+
+			rec := postJSON(srv, "/webhook", jsonOfSize(tt.size))
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d; body %s", rec.Code, tt.status, rec.Body)
+			}
+			if stored := srv.store.Len(); (tt.status == http.StatusOK) != (stored == 1) {
+				t.Errorf("%d webhooks stored for status %d", stored, tt.status)
+			}
+		})
+	}
+
+	t.Run("zero is unlimited", func(t *testing.T) {
+		srv := newTestServer(t, "-max-body", "0")
+		if rec := postJSON(srv, "/webhook", jsonOfSize(4<<20)); rec.Code != http.StatusOK {
+			t.Fatalf("status %d, want 200", rec.Code)
+		}
+	})
+}
+
+func TestSearchNewestFirst(t *testing.T) {
+	for _, mode := range []string{"lifo", "fifo"} {
+		t.Run(mode, func(t *testing.T) {
+			srv := newTestServer(t, "-mode", mode)
+			mustPost(t, srv, `{"msg":"hit"}`, `{"msg":"miss"}`, `{"msg":"hit again"}`)
+
+			rec := serve(srv, http.MethodGet, "/webhooks/search?q=hit", "", nil)
+			var resp struct {
+				Count    int             `json:"count"`
+				Webhooks []StoredWebhook `json:"webhooks"`
+			}
+			decodeResponse(t, rec, &resp)
+			if resp.Count != 2 || len(resp.Webhooks) != 2 || resp.Webhooks[0].ID != 3 || resp.Webhooks[1].ID != 1 {
+				t.Errorf("got count %d, webhooks %+v; want ids 3, 1", resp.Count, resp.Webhooks)
+			}
+		})
+	}
+}
+
+func TestImportBodyLimit(t *testing.T) {
+	line := `{"payload":{"n":1}}` + "\n"
+	srv := newTestServer(t, "-max-import-body", "100")
+
+	rec := serve(srv, http.MethodPost, "/webhooks/import", strings.Repeat(line, 4), nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("import under the limit: status %d, body %s", rec.Code, rec.Body)
+	}
+	rec = serve(srv, http.MethodPost, "/webhooks/import", strings.Repeat(line, 20), nil)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("import over the limit: status %d, want 413", rec.Code)
+	}
+}
+
+func TestWebhookIDPath(t *testing.T) {
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/webhooks/5", http.StatusOK},
+		{"/webhooks/5/", http.StatusOK},
+		{"/webhooks/abc", http.StatusNotFound}, // a valid bucket name that doesn't exist
+		{"/webhooks/abc!", http.StatusBadRequest},
+		{"/webhooks/9", http.StatusNotFound},
+		{"/webhooks/5/unknown", http.StatusNotFound},
+	}
+	srv := newTestServer(t)
+	mustPost(t, srv, `{"n":1}`, `{"n":2}`, `{"n":3}`, `{"n":4}`, `{"n":5}`)
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := serve(srv, http.MethodGet, tt.path, "", nil)
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d; body %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status == http.StatusOK {
+				var webhook StoredWebhook
+				decodeResponse(t, rec, &webhook)
+				if webhook.ID != 5 {
+					t.Errorf("got webhook %d, want 5", webhook.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestEmptyBodyVersusInvalidJSON(t *testing.T) {
+	srv := newTestServer(t)
+
+	rec := postJSON(srv, "/webhook", "")
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("empty body: status %d, want 400", rec.Code)
+	}
+	var resp errorResponse
+	decodeResponse(t, rec, &resp)
+	if resp.Error != "empty body" {
+		t.Errorf("empty body: error %q, want %q", resp.Error, "empty body")
+	}
+	if srv.store.Len() != 0 {
+		t.Fatal("empty body was stored")
+	}
+
+	// Undecodable bodies are kept raw with a null payload
+	rec = postJSON(srv, "/webhook", `{"broken":`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("invalid JSON: status %d, want 200; body %s", rec.Code, rec.Body)
+	}
+	stored, ok := srv.store.GetByID(1)
+	if !ok {
+		t.Fatal("invalid JSON was not stored")
+	}
+	if stored.Payload != nil || stored.RawBody != `{"broken":` {
+		t.Errorf("stored payload %v, raw body %q; want null payload and the raw body", stored.Payload, stored.RawBody)
+	}
+}
+
+func TestMaxBuckets(t *testing.T) {
+	srv := newTestServer(t, "-max-buckets", "2")
+
+	if rec := postJSON(srv, "/webhook/github", `{}`); rec.Code != http.StatusOK {
+		t.Fatalf("second bucket: status %d", rec.Code)
+	}
+	if rec := postJSON(srv, "/webhook/stripe", `{}`); rec.Code != http.StatusInsufficientStorage {
+		t.Fatalf("third bucket: status %d, want 507", rec.Code)
+	}
+	if _, ok := srv.buckets.Lookup("stripe"); ok {
+		t.Error("refused bucket was created")
+	}
+	if rec := postJSON(srv, "/webhook/github", `{}`); rec.Code != http.StatusOK {
+		t.Errorf("existing bucket at the limit: status %d", rec.Code)
+	}
+	if rec := postJSON(srv, "/webhook", `{}`); rec.Code != http.StatusOK {
+		t.Errorf("default bucket at the limit: status %d", rec.Code)
+	}
+
+	// Buckets found in storage are registered whatever the limit
+	srv.buckets.Preload("stripe")
+	if _, ok := srv.buckets.Lookup("stripe"); !ok {
+		t.Error("preloaded bucket missing")
+	}
+}
+
+func TestUnsupportedContentType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		status      int
+	}{
+		{"text/plain", http.StatusUnsupportedMediaType},
+		{"text/plain; charset=utf-8", http.StatusUnsupportedMediaType},
+		{"application/json", http.StatusOK},
+		{"application/vnd.github+json", http.StatusOK},
+		{"", http.StatusOK}, // lenient clients that send no type
+	}
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			srv := newTestServer(t)
+			header := http.Header{}
+			if tt.contentType != "" {
+				header.Set("Content-Type", tt.contentType)
+			}
+
+			rec := serve(srv, http.MethodPost, "/webhook", `{"n":1}`, header)
+			if rec.Code != tt.status {
+				t.Fatalf("status %d, want %d; body %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status == http.StatusUnsupportedMediaType {
+				var resp errorResponse
+				decodeResponse(t, rec, &resp)
+				if !strings.Contains(resp.Error, "application/json") {
+					t.Errorf("error %q doesn't list the accepted types", resp.Error)
+				}
+			}
+		})
+	}
+}
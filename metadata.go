@@ -0,0 +1,41 @@
+package main
+
+import "net/http"
+
+// Headers a provider uses to describe a delivery. Add an entry here to
+// surface another provider's metadata.
+type providerHeaders struct {
+	name           string
+	eventHeader    string
+	deliveryHeader string
+}
+
+var knownProviders = []providerHeaders{
+	{name: "github", eventHeader: "X-GitHub-Event", deliveryHeader: "X-GitHub-Delivery"},
+}
+
+// Pull the event name and delivery ID from provider headers. The event
+// falls back to the payload's "event" field when no header carries one.
+func extractMetadata(header http.Header, payload interface{}) (event, deliveryID string) {
+	for _, p := range knownProviders {
+		if event == "" {
+			event = header.Get(p.eventHeader)
+		}
+		if deliveryID == "" {
+			deliveryID = header.Get(p.deliveryHeader)
+		}
+	}
+	if event == "" {
+		event = getStringFromPayload(payload, "event")
+	}
+	return event, deliveryID
+}
+
+// Event name of a stored webhook, falling back to the payload for entries
+// stored before Event was recorded
+func webhookEvent(webhook StoredWebhook) string {
+	if webhook.Event != "" {
+		return webhook.Event
+	}
+	return getStringFromPayload(webhook.Payload, "event")
+}
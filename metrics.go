@@ -0,0 +1,128 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Reasons used for the rejected-webhooks counter
+const (
+	rejectMethod          = "method_not_allowed"
+	rejectBadBody         = "bad_body"
+	rejectEmptyBody       = "empty_body"
+	rejectTooLarge        = "too_large"
+	rejectUnsupportedType = "unsupported_media_type"
+	rejectSignature       = "bad_signature"
+	rejectSchema          = "schema_mismatch"
+	rejectRateLimited     = "rate_limited"
+	rejectInjected        = "injected_failure"
+	rejectOverloaded      = "overloaded"
+	rejectRuleMatched     = "rule_matched"
+	rejectSource          = "source_not_allowed"
+	rejectPaused          = "paused"
+	rejectTimestamp       = "bad_timestamp"
+	rejectStorage         = "storage_error"
+	rejectBucketLimit     = "bucket_limit"
+)
+
+// Label value standing in for events and buckets past the label limit
+const otherLabel = "other"
+
+type metrics struct {
+	received *prometheus.CounterVec
+	rejected *prometheus.CounterVec
+	bodySize prometheus.Histogram
+
+	// Bound the received counter's label values; see boundedLabels
+	events  *boundedLabels
+	buckets *boundedLabels
+}
+
+// Create the webhook collectors and register them with reg. labelLimit
+// caps the distinct event and bucket label values of the received counter.
+func newMetrics(reg prometheus.Registerer, store Store, labelLimit int) *metrics {
+	m := &metrics{
+		received: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "webhooks_received_total",
+			Help: "Total number of webhooks accepted and stored, by event and bucket.",
+		}, []string{"event", "bucket"}),
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "webhooks_rejected_total",
+			Help: "Total number of webhook requests rejected, by reason.",
+		}, []string{"reason"}),
+		bodySize: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "webhook_request_body_bytes",
+			Help:    "Size of incoming webhook request bodies in bytes.",
+			Buckets: prometheus.ExponentialBuckets(64, 4, 8),
+		}),
+		events:  newBoundedLabels(labelLimit),
+		buckets: newBoundedLabels(labelLimit),
+	}
+
+	stored := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "webhooks_stored",
+		Help: "Number of webhooks currently held in the store.",
+	}, func() float64 {
+		return float64(store.Len())
+	})
+
+	reg.MustRegister(m.received, m.rejected, m.bodySize, stored)
+	return m
+}
+
+// Count a stored webhook under its event and bucket
+func (m *metrics) countReceived(bucket, event string) {
+	if event == "" {
+		event = unknownEvent
+	}
+	m.received.WithLabelValues(m.events.Value(event), m.buckets.Value(bucket)).Inc()
+}
+
+// Label values admitted first come, first served up to a limit, after
+// which new values are reported as otherLabel. Senders choose event and
+// bucket names, so without this they could grow the series without bound.
+type boundedLabels struct {
+	mu    sync.Mutex
+	limit int
+	seen  map[string]struct{}
+}
+
+func newBoundedLabels(limit int) *boundedLabels {
+	return &boundedLabels{limit: limit, seen: make(map[string]struct{})}
+}
+
+// The label value to use for v
+func (b *boundedLabels) Value(v string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.seen[v]; ok {
+		return v
+	}
+	if len(b.seen) >= b.limit {
+		return otherLabel
+	}
+	b.seen[v] = struct{}{}
+	return v
+}
+
+// Register the forward queue depth gauge; only used when -forward is set
+func (m *metrics) registerForwarder(reg prometheus.Registerer, f *forwarder) {
+	reg.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "webhook_forward_queue_depth",
+		Help: "Number of forwards waiting for a delivery worker.",
+	}, func() float64 {
+		return float64(f.QueueDepth())
+	}))
+}
+
+// Register the gauge of webhook requests currently being processed
+func (m *metrics) registerConcurrency(reg prometheus.Registerer, l *concurrencyLimiter) {
+	reg.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "webhook_requests_in_flight",
+		Help: "Number of webhook requests currently being processed.",
+	}, func() float64 {
+		return float64(l.InFlight())
+	}))
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Response formats GET /webhooks can produce
+type listFormat int
+
+const (
+	listJSON listFormat = iota
+	listNDJSON
+	listCSV
+)
+
+// Media types and ranges each format answers to. application/json+pretty
+// is JSON; wantsPretty picks up the indenting.
+var listFormatTypes = map[string]listFormat{
+	"*/*":                     listJSON,
+	"application/*":           listJSON,
+	"application/json":        listJSON,
+	"application/json+pretty": listJSON,
+	"application/x-ndjson":    listNDJSON,
+	"text/csv":                listCSV,
+	"text/*":                  listCSV,
+}
+
+// Pick the list format from the Accept header, preferring higher q values
+// and then the order the client gave. A missing header means JSON; false
+// means nothing acceptable is supported.
+func negotiateListFormat(r *http.Request) (listFormat, bool) {
+	accept := r.Header.Get("Accept")
+	if strings.TrimSpace(accept) == "" {
+		return listJSON, true
+	}
+
+	type mediaRange struct {
+		format listFormat
+		q      float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		format, ok := listFormatTypes[mediaType]
+		if !ok {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, mediaRange{format, q})
+		}
+	}
+	if len(ranges) == 0 {
+		return 0, false
+	}
+	slices.SortStableFunc(ranges, func(a, b mediaRange) int {
+		switch {
+		case a.q > b.q:
+			return -1
+		case a.q < b.q:
+			return 1
+		}
+		return 0
+	})
+	return ranges[0].format, true
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type jsonObject = map[string]interface{}
+
+// Who may call an operation, which decides its security requirement
+type apiAccess int
+
+const (
+	accessPublic apiAccess = iota
+	accessRead
+	accessAdmin
+)
+
+type apiParam struct {
+	name        string
+	in          string
+	description string
+	schema      jsonObject
+}
+
+// One method on one path of the OpenAPI document
+type apiOperation struct {
+	method  string
+	path    string
+	summary string
+	access  apiAccess
+	params  []apiParam
+	// Request body schema, sent as bodyType (application/json by default)
+	body     jsonObject
+	bodyType string
+	// Success response schema, sent as okType (application/json by default)
+	ok     jsonObject
+	okType string
+	status int
+}
+
+func queryParam(name, description string, schema jsonObject) apiParam {
+	return apiParam{name: name, in: "query", description: description, schema: schema}
+}
+
+func pathParam(name, description string, schema jsonObject) apiParam {
+	return apiParam{name: name, in: "path", description: description, schema: schema}
+}
+
+func schemaRef(name string) jsonObject {
+	return jsonObject{"$ref": "#/components/schemas/" + name}
+}
+
+func typed(t string) jsonObject {
+	return jsonObject{"type": t}
+}
+
+func arrayOf(items jsonObject) jsonObject {
+	return jsonObject{"type": "array", "items": items}
+}
+
+var (
+	// Accepted by every endpoint that goes through filterList
+	filterParams = []apiParam{
+		queryParam("event", "Only webhooks with this event", typed("string")),
+		queryParam("match", "Set to prefix to match event as a prefix", jsonObject{"type": "string", "enum": []string{"prefix"}}),
+		queryParam("since", "Earliest received time, RFC3339, inclusive", jsonObject{"type": "string", "format": "date-time"}),
+		queryParam("until", "Latest received time, RFC3339, inclusive", jsonObject{"type": "string", "format": "date-time"}),
+		queryParam("max_age", "Only webhooks received within this Go duration, e.g. 10m", typed("string")),
+		queryParam("label", "Only webhooks with this label", typed("string")),
+		queryParam("hash", "Only webhooks whose canonical payload has this SHA-256", typed("string")),
+	}
+	orderParam = queryParam("order", "asc for oldest first, desc for newest first", jsonObject{"type": "string", "enum": []string{"asc", "desc"}})
+	// GET /webhooks and GET /webhooks/{bucket}
+	listParams = append(slices.Clone(filterParams),
+		queryParam("limit", "Maximum webhooks to return", typed("integer")),
+		queryParam("offset", "Webhooks to skip", typed("integer")),
+		orderParam,
+	)
+
+	bucketQueryParam = queryParam("bucket", "Bucket name (defaults to the default bucket)", typed("string"))
+	idPathParam      = pathParam("id", "Webhook ID", typed("integer"))
+	bucketPathParam  = pathParam("bucket", "Bucket name", typed("string"))
+)
+
+// Operations served with the current configuration, for /openapi.json.
+// Routes added to routes() need an entry here too.
+func (s *server) apiOperations() []apiOperation {
+	accepted := jsonObject{"type": "object", "properties": jsonObject{
+		"id":         typed("integer"),
+		"message":    typed("string"),
+		"request_id": typed("string"),
+		"webhook":    schemaRef("StoredWebhook"),
+	}}
+	message := jsonObject{"type": "object", "properties": jsonObject{"message": typed("string")}}
+	anyBody := jsonObject{}
+
+	ops := []apiOperation{
+		{method: http.MethodPost, path: "/webhook", summary: "Receive a webhook into the default bucket", body: anyBody, bodyType: "*/*", ok: accepted, status: s.cfg.ackStatus},
+		{method: http.MethodPost, path: "/webhook/{bucket}", summary: "Receive a webhook into a named bucket", params: []apiParam{bucketPathParam}, body: anyBody, bodyType: "*/*", ok: accepted, status: s.cfg.ackStatus},
+		{method: http.MethodPost, path: "/webhook/validate", summary: "Dry-run a webhook through decoding, signature and schema checks without storing it", params: []apiParam{bucketQueryParam}, body: anyBody, bodyType: "*/*", ok: schemaRef("ValidationResult")},
+
+		{method: http.MethodGet, path: "/webhooks", summary: "List webhooks, as JSON, NDJSON or CSV depending on Accept", access: accessRead, params: listParams, ok: schemaRef("WebhookList")},
+		// /webhooks/{bucket} shares this path, which OpenAPI can't tell apart
+		{method: http.MethodGet, path: "/webhooks/{id}", summary: "Get a webhook by numeric ID, or list a named bucket (taking the /webhooks query parameters)", access: accessRead,
+			params: append([]apiParam{pathParam("id", "Webhook ID or bucket name", typed("string"))}, listParams...),
+			ok:     jsonObject{"oneOf": []jsonObject{schemaRef("StoredWebhook"), schemaRef("WebhookList")}}},
+		{method: http.MethodDelete, path: "/webhooks/{id}", summary: "Delete a webhook by ID", access: accessAdmin, params: []apiParam{idPathParam}, ok: message},
+		{method: http.MethodPost, path: "/webhooks/{id}/replay", summary: "Re-send a stored webhook to a target URL", access: accessRead, params: []apiParam{idPathParam},
+			body: jsonObject{"type": "object", "required": []string{"target"}, "properties": jsonObject{"target": jsonObject{"type": "string", "format": "uri"}}}},
+		{method: http.MethodGet, path: "/webhooks/latest", summary: "Get the most recent webhook", access: accessRead, params: []apiParam{queryParam("event", "Latest of this event only", typed("string"))}, ok: schemaRef("StoredWebhook")},
+		{method: http.MethodGet, path: "/webhooks/by-day", summary: "Webhooks grouped by received day in the " + s.cfg.location.String() + " timezone, newest day first", access: accessRead, params: filterParams,
+			ok: jsonObject{"type": "object", "properties": jsonObject{
+				"timezone": typed("string"),
+				"count":    typed("integer"),
+				"days":     jsonObject{"type": "object", "additionalProperties": arrayOf(schemaRef("StoredWebhook"))},
+			}}},
+		{method: http.MethodGet, path: "/webhooks/search", summary: "Search webhook payloads", access: accessRead, params: []apiParam{
+			queryParam("q", "Text to look for", typed("string")),
+			queryParam("field", "Only search this payload field", typed("string")),
+		}, ok: schemaRef("WebhookList")},
+		{method: http.MethodGet, path: "/webhooks/export", summary: "Export all webhooks as NDJSON", access: accessRead, params: []apiParam{orderParam}, ok: schemaRef("StoredWebhook"), okType: "application/x-ndjson"},
+		{method: http.MethodGet, path: "/webhooks/export.csv", summary: "Export webhook metadata as CSV", access: accessRead, params: []apiParam{orderParam}, ok: typed("string"), okType: "text/csv"},
+		{method: http.MethodGet, path: "/webhooks/stats", summary: "Summary of stored webhooks", access: accessRead, ok: schemaRef("Stats")},
+		{method: http.MethodPost, path: "/webhooks/import", summary: "Import webhooks from NDJSON", access: accessAdmin, body: schemaRef("StoredWebhook"), bodyType: "application/x-ndjson"},
+		{method: http.MethodPost, path: "/webhooks/clear", summary: "Clear all webhooks", access: accessAdmin, ok: message},
+		{method: http.MethodDelete, path: "/webhooks/clear", summary: "Clear all webhooks", access: accessAdmin, ok: message},
+
+		{method: http.MethodGet, path: "/buckets", summary: "List buckets and their counts", access: accessRead},
+		{method: http.MethodPut, path: "/config/max-size", summary: "Change a bucket's max size at runtime", access: accessAdmin, params: []apiParam{bucketQueryParam},
+			body: jsonObject{"type": "object", "required": []string{"max_size"}, "properties": jsonObject{"max_size": typed("integer")}}},
+		{method: http.MethodPost, path: "/admin/pause", summary: "Stop accepting webhooks", access: accessAdmin},
+		{method: http.MethodPost, path: "/admin/resume", summary: "Accept webhooks again", access: accessAdmin},
+
+		{method: http.MethodGet, path: "/", summary: "HTML dashboard of the newest webhooks", access: accessRead, ok: typed("string"), okType: "text/html"},
+		{method: http.MethodGet, path: "/healthz", summary: "Liveness probe"},
+		{method: http.MethodGet, path: "/readyz", summary: "Readiness probe"},
+		{method: http.MethodGet, path: "/metrics", summary: "Prometheus metrics", ok: typed("string"), okType: "text/plain"},
+		{method: http.MethodGet, path: "/version", summary: "Build version, commit and time", ok: schemaRef("BuildInfo")},
+		{method: http.MethodGet, path: "/openapi.json", summary: "This document"},
+	}
+
+	// Streaming and snapshots depend on the store backend
+	if _, ok := s.store.(subscribableStore); ok {
+		ops = append(ops,
+			apiOperation{method: http.MethodGet, path: "/webhooks/stream", summary: "Stream new webhooks as Server-Sent Events", access: accessRead, ok: typed("string"), okType: "text/event-stream"},
+			apiOperation{method: http.MethodGet, path: "/ws", summary: "WebSocket stream of new webhooks", access: accessRead, status: http.StatusSwitchingProtocols},
+		)
+	}
+	if _, ok := s.store.(snapshottableStore); ok {
+		ops = append(ops,
+			apiOperation{method: http.MethodGet, path: "/admin/snapshot", summary: "Dump a bucket's full state", access: accessAdmin, params: []apiParam{bucketQueryParam}, ok: schemaRef("Snapshot")},
+			apiOperation{method: http.MethodPost, path: "/admin/restore", summary: "Replace a bucket's state with a snapshot", access: accessAdmin, params: []apiParam{bucketQueryParam}, body: schemaRef("Snapshot")},
+		)
+	}
+	return ops
+}
+
+// Build the OpenAPI 3 document for the current configuration
+func (s *server) openAPISpec() jsonObject {
+	paths := jsonObject{}
+	for _, op := range s.apiOperations() {
+		item, ok := paths[op.path].(jsonObject)
+		if !ok {
+			item = jsonObject{}
+			paths[op.path] = item
+		}
+		item[strings.ToLower(op.method)] = s.openAPIOperation(op)
+	}
+
+	schemas := jsonObject{
+		"StoredWebhook":    schemaOf(reflect.TypeFor[StoredWebhook]()),
+		"Error":            schemaOf(reflect.TypeFor[errorResponse]()),
+		"Stats":            schemaOf(reflect.TypeFor[webhookStats]()),
+		"Snapshot":         schemaOf(reflect.TypeFor[storeSnapshot]()),
+		"ValidationResult": schemaOf(reflect.TypeFor[validationResult]()),
+		"BuildInfo":        schemaOf(reflect.TypeFor[buildInfo]()),
+		"WebhookList": jsonObject{"type": "object", "properties": jsonObject{
+			"count":     typed("integer"),
+			"returned":  typed("integer"),
+			"max_size":  typed("integer"),
+			"next_id":   typed("integer"),
+			"remaining": typed("integer"),
+			"webhooks":  arrayOf(schemaRef("StoredWebhook")),
+		}},
+	}
+	schemas["Snapshot"].(jsonObject)["properties"].(jsonObject)["webhooks"] = arrayOf(schemaRef("StoredWebhook"))
+
+	securitySchemes := jsonObject{}
+	if s.cfg.readUser != "" {
+		securitySchemes["readAuth"] = jsonObject{"type": "http", "scheme": "basic"}
+	}
+	if s.cfg.adminToken != "" {
+		securitySchemes["adminToken"] = jsonObject{"type": "apiKey", "in": "header", "name": adminTokenHeader}
+	}
+
+	spec := jsonObject{
+		"openapi": "3.0.3",
+		"info": jsonObject{
+			"title":   "webhook-receiver",
+			"version": readBuildInfo().Version,
+		},
+		"paths":      paths,
+		"components": jsonObject{"schemas": schemas, "securitySchemes": securitySchemes},
+	}
+	if s.cfg.basePath != "" {
+		spec["servers"] = []jsonObject{{"url": s.cfg.basePath}}
+	}
+	return spec
+}
+
+func (s *server) openAPIOperation(op apiOperation) jsonObject {
+	operation := jsonObject{"summary": op.summary}
+
+	if len(op.params) > 0 {
+		params := make([]jsonObject, len(op.params))
+		for i, p := range op.params {
+			params[i] = jsonObject{
+				"name":        p.name,
+				"in":          p.in,
+				"description": p.description,
+				"required":    p.in == "path",
+				"schema":      p.schema,
+			}
+		}
+		operation["parameters"] = params
+	}
+
+	if op.body != nil {
+		bodyType := op.bodyType
+		if bodyType == "" {
+			bodyType = "application/json"
+		}
+		operation["requestBody"] = jsonObject{
+			"required": true,
+			"content":  jsonObject{bodyType: jsonObject{"schema": op.body}},
+		}
+	}
+
+	status := op.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := jsonObject{"description": http.StatusText(status)}
+	if op.ok != nil {
+		okType := op.okType
+		if okType == "" {
+			okType = "application/json"
+		}
+		success["content"] = jsonObject{okType: jsonObject{"schema": op.ok}}
+	}
+	operation["responses"] = jsonObject{
+		strconv.Itoa(status): success,
+		"default": jsonObject{
+			"description": "Error",
+			"content":     jsonObject{"application/json": jsonObject{"schema": schemaRef("Error")}},
+		},
+	}
+
+	switch {
+	case op.access == accessRead && s.cfg.readUser != "":
+		operation["security"] = []jsonObject{{"readAuth": []string{}}}
+	case op.access == accessAdmin && s.cfg.adminToken != "":
+		operation["security"] = []jsonObject{{"adminToken": []string{}}}
+	}
+	return operation
+}
+
+// JSON Schema for a Go type as encoding/json would marshal it
+func schemaOf(t reflect.Type) jsonObject {
+	if t == reflect.TypeFor[time.Time]() {
+		return jsonObject{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := schemaOf(t.Elem())
+		schema["nullable"] = true
+		return schema
+	case reflect.Interface:
+		return jsonObject{}
+	case reflect.Bool:
+		return typed("boolean")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return typed("integer")
+	case reflect.Float32, reflect.Float64:
+		return typed("number")
+	case reflect.String:
+		return typed("string")
+	case reflect.Slice, reflect.Array:
+		return arrayOf(schemaOf(t.Elem()))
+	case reflect.Map:
+		return jsonObject{"type": "object", "additionalProperties": schemaOf(t.Elem())}
+	case reflect.Struct:
+		properties := jsonObject{}
+		var required []string
+		for i := range t.NumField() {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if field.Anonymous && name == "" {
+				for k, v := range schemaOf(field.Type)["properties"].(jsonObject) {
+					properties[k] = v
+				}
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			properties[name] = schemaOf(field.Type)
+			if !strings.Contains(opts, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		schema := jsonObject{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	}
+	return jsonObject{}
+}
+
+// GET /openapi.json
+func (s *server) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, r, s.openAPISpec())
+}
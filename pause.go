@@ -0,0 +1,55 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// Retry-After sent to senders while acceptance is paused
+const pausedRetryAfter = 30
+
+// Answer webhooks with 503 while paused by /admin/pause, so senders retry
+// once maintenance is over. Reads aren't affected.
+func (s *server) rejectWhilePaused(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.paused.Load() {
+			w.Header().Set("Retry-After", strconv.Itoa(pausedRetryAfter))
+			s.reject(w, r, rejectPaused, "Webhook acceptance is paused", http.StatusServiceUnavailable)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// POST /admin/pause
+func (s *server) pauseHandler(w http.ResponseWriter, r *http.Request) {
+	s.setPaused(w, r, true)
+}
+
+// POST /admin/resume
+func (s *server) resumeHandler(w http.ResponseWriter, r *http.Request) {
+	s.setPaused(w, r, false)
+}
+
+func (s *server) setPaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	changed := s.paused.Swap(paused) != paused
+	if changed {
+		slog.Info("webhook acceptance changed", "accepting", !paused, "remote_addr", s.clientIP(r))
+	}
+	message := "Webhook acceptance resumed"
+	if paused {
+		message = "Webhook acceptance paused"
+	}
+
+	writeJSON(w, r, map[string]interface{}{
+		"message":   message,
+		"accepting": !paused,
+		"changed":   changed,
+	})
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// How long the block policy waits for persist queue space before skipping
+// a write
+const persistBlockTimeout = time.Second
+
+// On-disk representation of the store
+type persistedState struct {
+	NextID   int             `json:"nextID"`
+	Webhooks []StoredWebhook `json:"webhooks"`
+
+	seq uint64 // order of the save, for the background writer
+}
+
+// Load webhooks previously written to path. A missing file is treated as an
+// empty store.
+func (ws *WebhookStore) Load(path string) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	ws.persistPath = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	var state persistedState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("decoding %s: %w", path, err)
+	}
+
+	ws.replaceLocked(state.Webhooks)
+
+	ws.nextID = state.NextID
+	for _, webhook := range state.Webhooks {
+		if webhook.ID >= ws.nextID {
+			ws.nextID = webhook.ID + 1
+		}
+	}
+	if ws.nextID < 1 {
+		ws.nextID = 1
+	}
+
+	return nil
+}
+
+// Replace the stack with webhooks, given oldest first, evicting down to
+// the caps. Callers must hold ws.mu and set nextID themselves.
+func (ws *WebhookStore) replaceLocked(webhooks []StoredWebhook) {
+	ws.webhooks.Reset()
+	ws.bytes = 0
+	ws.events = make(map[string][]int)
+	for _, webhook := range webhooks {
+		webhook.size = webhookSize(webhook)
+		if evicted, full := ws.webhooks.Push(webhook); full {
+			ws.forgetLocked(evicted)
+			ws.evictedLocked(evictOverflow, evicted)
+		}
+		ws.indexLocked(webhook)
+	}
+	ws.evictLocked()
+}
+
+// Write the current stack to disk, first letting the background writer
+// finish so nothing older lands after it. Used at shutdown; later saves are
+// synchronous.
+func (ws *WebhookStore) Flush() error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if ws.persistQueue != nil {
+		close(ws.persistStop)
+		<-ws.persistDone
+		ws.persistQueue = nil
+		ws.pendingSave = nil
+	}
+	return ws.saveLocked()
+}
+
+// Move file writes to a background goroutine fed by a queue of queueSize
+// snapshots. Bursts are coalesced into one write of the newest state. When
+// the queue is full, overflow decides whether the write waits briefly for
+// space, outside ws.mu, or is skipped, which the next save makes up for.
+func (ws *WebhookStore) StartAsyncPersist(queueSize int, overflow overflowPolicy) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	queue := make(chan persistedState, queueSize)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	ws.persistQueue, ws.persistStop, ws.persistDone, ws.persistOverflow = queue, stop, done, overflow
+
+	go func() {
+		defer close(done)
+		var written uint64
+		for {
+			var state persistedState
+			select {
+			case state = <-queue:
+			case <-stop:
+				return
+			}
+			// Only the newest of the queued states matters. A blocked save
+			// can arrive after a newer one, so go by seq, not arrival.
+		drain:
+			for {
+				select {
+				case next := <-queue:
+					if next.seq > state.seq {
+						state = next
+					}
+				default:
+					break drain
+				}
+			}
+			if state.seq <= written {
+				continue
+			}
+			if err := writeState(ws.persistPath, state); err != nil {
+				slog.Error("failed to persist webhooks", "error", err)
+			}
+			written = state.seq
+		}
+	}()
+}
+
+// Save the current stack, handing it to the background writer when async
+// persistence is on. Callers must hold ws.mu, and release it with unlock so
+// a save waiting on a full queue is handed over.
+func (ws *WebhookStore) saveLocked() error {
+	if ws.persistPath == "" {
+		return nil
+	}
+
+	ws.persistSeq++
+	state := persistedState{
+		NextID:   ws.nextID,
+		Webhooks: ws.webhooks.Slice(),
+		seq:      ws.persistSeq,
+	}
+	if ws.persistQueue == nil {
+		return writeState(ws.persistPath, state)
+	}
+
+	select {
+	case ws.persistQueue <- state:
+		return nil
+	default:
+	}
+	if ws.persistOverflow == overflowBlock {
+		// Waiting here would stall every reader and writer
+		ws.pendingSave = &state
+		return nil
+	}
+	slog.Warn("persist queue full, skipping write", "policy", ws.persistOverflow)
+	return nil
+}
+
+// Release ws.mu, then wait up to persistBlockTimeout for queue space for a
+// save the block policy held back
+func (ws *WebhookStore) unlock() {
+	state, queue, stop, policy := ws.pendingSave, ws.persistQueue, ws.persistStop, ws.persistOverflow
+	ws.pendingSave = nil
+	ws.mu.Unlock()
+	if state == nil {
+		return
+	}
+
+	timer := time.NewTimer(persistBlockTimeout)
+	defer timer.Stop()
+	select {
+	case queue <- *state:
+	case <-stop:
+		// Flush writes the final state itself
+	case <-timer.C:
+		slog.Warn("persist queue full, skipping write", "policy", policy)
+	}
+}
+
+// Write state to path. The file is written to a temp file and renamed into
+// place so a crash mid-write never leaves a truncated store behind.
+func writeState(path string, state persistedState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+
+	return os.Rename(tmpName, path)
+}
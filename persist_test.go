@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Without -persist-queue every acknowledged webhook is on disk already
+func TestPersistSynchronousByDefault(t *testing.T) {
+	cfg, err := loadConfig(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.persistQueue != 0 {
+		t.Errorf("-persist-queue defaults to %d, want 0", cfg.persistQueue)
+	}
+
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+	ws := NewWebhookStore(10, 0, modeLIFO)
+	if err := ws.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	ws.Add(StoredWebhook{Payload: map[string]interface{}{"n": 1}})
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state persistedState
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Webhooks) != 1 || state.NextID != 2 {
+		t.Errorf("file holds %d webhooks, next id %d; want 1 and 2", len(state.Webhooks), state.NextID)
+	}
+}
+
+// The block policy waits for queue space with ws.mu released
+func TestPersistBlockDoesNotHoldLock(t *testing.T) {
+	ws := NewWebhookStore(10, 0, modeLIFO)
+	ws.persistPath = filepath.Join(t.TempDir(), "webhooks.json")
+	// A queue nobody drains yet, already full
+	ws.persistQueue = make(chan persistedState, 1)
+	ws.persistStop = make(chan struct{})
+	ws.persistOverflow = overflowBlock
+	ws.persistQueue <- persistedState{}
+
+	added := make(chan struct{})
+	go func() {
+		ws.Add(StoredWebhook{})
+		close(added)
+	}()
+
+	// Readers get in while the save is still waiting
+	for ws.Len() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case <-added:
+		t.Fatal("Add returned before the queue had space; the reader was stalled behind it")
+	default:
+	}
+
+	<-ws.persistQueue
+	if state := <-ws.persistQueue; len(state.Webhooks) != 1 || state.seq == 0 {
+		t.Errorf("blocked save handed over %d webhooks, seq %d", len(state.Webhooks), state.seq)
+	}
+	<-added
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Apply every list filter from the query string in turn. Ages are measured
+// from now, which should come from the same clock as Received.
+func filterList(webhooks []StoredWebhook, query url.Values, now time.Time) ([]StoredWebhook, error) {
+	filters := []func([]StoredWebhook, url.Values) ([]StoredWebhook, error){
+		filterByEvent,
+		filterByTime,
+		func(webhooks []StoredWebhook, query url.Values) ([]StoredWebhook, error) {
+			return filterByAge(webhooks, query, now)
+		},
+		filterByHash,
+		filterByLabel,
+	}
+	for _, filter := range filters {
+		var err error
+		if webhooks, err = filter(webhooks, query); err != nil {
+			return nil, err
+		}
+	}
+	return webhooks, nil
+}
+
+// Return the webhooks for which keep returns true, preserving order
+func filterWebhooks(webhooks []StoredWebhook, keep func(StoredWebhook) bool) []StoredWebhook {
+	result := make([]StoredWebhook, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		if keep(webhook) {
+			result = append(result, webhook)
+		}
+	}
+	return result
+}
+
+// Apply the ?event= filter. Matching is exact and case-sensitive unless
+// ?match=prefix is given.
+func filterByEvent(webhooks []StoredWebhook, query url.Values) ([]StoredWebhook, error) {
+	event := query.Get("event")
+	match := query.Get("match")
+	if match != "" && match != "exact" && match != "prefix" {
+		return nil, fmt.Errorf("invalid match %q, expected exact or prefix", match)
+	}
+	if event == "" {
+		return webhooks, nil
+	}
+
+	return filterWebhooks(webhooks, func(webhook StoredWebhook) bool {
+		got := webhookEvent(webhook)
+		if match == "prefix" {
+			return strings.HasPrefix(got, event)
+		}
+		return got == event
+	}), nil
+}
+
+// Apply the ?label= filter, an exact match on the -label-header value
+func filterByLabel(webhooks []StoredWebhook, query url.Values) ([]StoredWebhook, error) {
+	label := query.Get("label")
+	if label == "" {
+		return webhooks, nil
+	}
+	return filterWebhooks(webhooks, func(webhook StoredWebhook) bool {
+		return webhook.Label == label
+	}), nil
+}
+
+// Apply ?offset= and ?limit= to an already ordered and filtered list. The
+// limit defaults to everything and is clamped to what's available.
+func paginate(webhooks []StoredWebhook, query url.Values) ([]StoredWebhook, error) {
+	offset, err := parseNonNegative(query, "offset", 0)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := parseNonNegative(query, "limit", len(webhooks))
+	if err != nil {
+		return nil, err
+	}
+
+	if offset >= len(webhooks) {
+		return []StoredWebhook{}, nil
+	}
+	// Clamp before adding, so a huge limit can't overflow
+	limit = min(limit, len(webhooks)-offset)
+	return webhooks[offset : offset+limit], nil
+}
+
+func parseNonNegative(query url.Values, key string, fallback int) (int, error) {
+	v := query.Get(key)
+	if v == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s %q, expected a non-negative integer", key, v)
+	}
+	return n, nil
+}
+
+// Apply the inclusive ?since= and ?until= RFC3339 bounds on Received
+func filterByTime(webhooks []StoredWebhook, query url.Values) ([]StoredWebhook, error) {
+	since, err := parseTime(query, "since")
+	if err != nil {
+		return nil, err
+	}
+	until, err := parseTime(query, "until")
+	if err != nil {
+		return nil, err
+	}
+	if since.IsZero() && until.IsZero() {
+		return webhooks, nil
+	}
+
+	return filterWebhooks(webhooks, func(webhook StoredWebhook) bool {
+		if !since.IsZero() && webhook.Received.Before(since) {
+			return false
+		}
+		if !until.IsZero() && webhook.Received.After(until) {
+			return false
+		}
+		return true
+	}), nil
+}
+
+func parseTime(query url.Values, key string) (time.Time, error) {
+	v := query.Get(key)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q, expected an RFC3339 timestamp", key, v)
+	}
+	return t, nil
+}
+
+// Hide webhooks received more than ?max_age= ago, without deleting them
+func filterByAge(webhooks []StoredWebhook, query url.Values, now time.Time) ([]StoredWebhook, error) {
+	v := query.Get("max_age")
+	if v == "" {
+		return webhooks, nil
+	}
+	maxAge, err := time.ParseDuration(v)
+	if err != nil || maxAge < 0 {
+		return nil, fmt.Errorf("invalid max_age %q, expected a duration such as 10m", v)
+	}
+
+	cutoff := now.Add(-maxAge)
+	return filterWebhooks(webhooks, func(webhook StoredWebhook) bool {
+		return !webhook.Received.Before(cutoff)
+	}), nil
+}
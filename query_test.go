@@ -0,0 +1,85 @@
+package main
+
+import (
+	"math"
+	"net/url"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPaginate(t *testing.T) {
+	webhooks := make([]StoredWebhook, 5)
+	for i := range webhooks {
+		webhooks[i].ID = i + 1
+	}
+	maxInt := strconv.Itoa(math.MaxInt)
+
+	tests := []struct {
+		query   string
+		want    []int
+		wantErr bool
+	}{
+		{"", []int{1, 2, 3, 4, 5}, false},
+		{"limit=2", []int{1, 2}, false},
+		{"offset=3", []int{4, 5}, false},
+		{"offset=1&limit=2", []int{2, 3}, false},
+		{"offset=4&limit=10", []int{5}, false},
+		{"offset=5", []int{}, false},
+		{"limit=0", []int{}, false},
+		{"offset=1&limit=" + maxInt, []int{2, 3, 4, 5}, false},
+		{"offset=" + maxInt + "&limit=" + maxInt, []int{}, false},
+		{"offset=-1", nil, true},
+		{"limit=ten", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			query, _ := url.ParseQuery(tt.query)
+			page, err := paginate(webhooks, query)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("no error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := []int{}
+			for _, webhook := range page {
+				ids = append(ids, webhook.ID)
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("ids %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxAgeUsesStoreClock(t *testing.T) {
+	srv := newTestServer(t)
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	srv.now = clock.Now
+	srv.store.(*WebhookStore).now = clock.Now
+
+	mustPost(t, srv, `{"n":1}`)
+	clock.Advance(10 * time.Minute)
+	mustPost(t, srv, `{"n":2}`)
+	clock.Advance(time.Minute)
+
+	tests := []struct {
+		maxAge string
+		want   []int
+	}{
+		{"1m", []int{2}},
+		{"5m", []int{2}},
+		{"11m", []int{2, 1}},
+		{"30s", []int{}},
+	}
+	for _, tt := range tests {
+		if ids := listedIDsAt(t, srv, "/webhooks?max_age="+tt.maxAge); !slices.Equal(ids, tt.want) {
+			t.Errorf("max_age=%s: ids %v, want %v", tt.maxAge, ids, tt.want)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	rateLimitCleanupInterval = time.Minute
+	rateLimitIdleTimeout     = 10 * time.Minute
+)
+
+// Per-client-IP token buckets
+type rateLimiter struct {
+	mu      sync.Mutex
+	clients map[string]*clientLimiter
+	limit   rate.Limit
+	burst   int
+}
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newRateLimiter(perSecond float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		clients: make(map[string]*clientLimiter),
+		limit:   rate.Limit(perSecond),
+		burst:   burst,
+	}
+}
+
+// Take a token for ip. When none is available, report how long until one is.
+func (rl *rateLimiter) Allow(ip string) (bool, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	client, ok := rl.clients[ip]
+	if !ok {
+		client = &clientLimiter{limiter: rate.NewLimiter(rl.limit, rl.burst)}
+		rl.clients[ip] = client
+	}
+	client.lastSeen = now
+
+	res := client.limiter.ReserveN(now, 1)
+	if !res.OK() {
+		return false, time.Second
+	}
+	if delay := res.DelayFrom(now); delay > 0 {
+		res.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// Forget clients that haven't been seen for a while
+func (rl *rateLimiter) cleanup(idle time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	cutoff := time.Now().Add(-idle)
+	for ip, client := range rl.clients {
+		if client.lastSeen.Before(cutoff) {
+			delete(rl.clients, ip)
+		}
+	}
+}
+
+// Periodically drop idle clients until ctx is cancelled
+func (rl *rateLimiter) cleanupLoop(ctx context.Context) {
+	ticker := time.NewTicker(rateLimitCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			rl.cleanup(rateLimitIdleTimeout)
+		}
+	}
+}
+
+// Reject requests from clients that have exhausted their bucket with 429
+func (s *server) rateLimit(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.limiter == nil {
+			next(w, r)
+			return
+		}
+
+		ok, retryAfter := s.limiter.Allow(s.clientIP(r))
+		if !ok {
+			seconds := int(math.Ceil(retryAfter.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			s.reject(w, r, rejectRateLimited, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next(w, r)
+	}
+}
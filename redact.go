@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"slices"
+)
+
+// Mask the given top-level keys in a decoded payload, or in each object of
+// a top-level array, returning the redacted payload and a raw body to store
+// in its place. Payloads without any of the keys are returned unchanged
+// along with the original body. A body that didn't decode can't be checked,
+// so its raw form is masked whole.
+func redactPayload(payload interface{}, body []byte, keys []string) (interface{}, []byte) {
+	if len(keys) == 0 {
+		return payload, body
+	}
+
+	switch p := payload.(type) {
+	case nil:
+		if len(bytes.TrimSpace(body)) == 0 || string(bytes.TrimSpace(body)) == "null" {
+			return payload, body
+		}
+		return nil, []byte(redactedValue)
+
+	case map[string]interface{}, []interface{}:
+		redacted, changed := redactKeys(p, keys)
+		if !changed {
+			return payload, body
+		}
+		if json.Valid(body) {
+			if raw, err := json.Marshal(redacted); err == nil {
+				return redacted, raw
+			}
+		}
+		// XML, which can't be re-encoded faithfully; drop the raw body
+		// rather than keep the secrets in it
+		return redacted, []byte(redactedValue)
+
+	case map[string][]string:
+		redacted := make(map[string][]string, len(p))
+		changed := false
+		for k, v := range p {
+			redacted[k] = v
+		}
+		for _, key := range keys {
+			if values, ok := redacted[key]; ok {
+				masked := make([]string, len(values))
+				for i := range masked {
+					masked[i] = redactedValue
+				}
+				redacted[key] = masked
+				changed = true
+			}
+		}
+		if !changed {
+			return payload, body
+		}
+		return redacted, []byte(url.Values(redacted).Encode())
+	}
+
+	return payload, body
+}
+
+// Copy of a JSON object with keys masked, or of an array with that done to
+// each element. Reports whether anything was masked; when not, value is
+// returned as is.
+func redactKeys(value interface{}, keys []string) (interface{}, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		changed := false
+		for _, key := range keys {
+			if _, ok := v[key]; ok {
+				changed = true
+				break
+			}
+		}
+		if !changed {
+			return value, false
+		}
+		redacted := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			redacted[k] = val
+		}
+		for _, key := range keys {
+			if _, ok := redacted[key]; ok {
+				redacted[key] = redactedValue
+			}
+		}
+		return redacted, true
+
+	case []interface{}:
+		var redacted []interface{}
+		for i, element := range v {
+			masked, changed := redactKeys(element, keys)
+			if !changed {
+				continue
+			}
+			if redacted == nil {
+				redacted = slices.Clone(v)
+			}
+			redacted[i] = masked
+		}
+		if redacted == nil {
+			return value, false
+		}
+		return redacted, true
+	}
+	return value, false
+}
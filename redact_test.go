@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRedactPayload(t *testing.T) {
+	keys := []string{"password", "token"}
+	tests := []struct {
+		name    string
+		body    string
+		wantRaw string
+	}{
+		{"object", `{"user":"amy","password":"hunter2"}`, `{"password":"[REDACTED]","user":"amy"}`},
+		{"object without the keys", `{"user":"amy"}`, `{"user":"amy"}`},
+		{"array of objects", `[{"token":"t1","n":1},{"n":2},{"token":"t3"}]`, `[{"n":1,"token":"[REDACTED]"},{"n":2},{"token":"[REDACTED]"}]`},
+		{"nested array", `[[{"token":"t1"}]]`, `[[{"token":"[REDACTED]"}]]`},
+		{"array without the keys", `[{"n":1},2,"x"]`, `[{"n":1},2,"x"]`},
+		{"undecodable body", `{"password":"hunter2"`, redactedValue},
+		{"empty body", ``, ``},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var payload interface{}
+			if err := json.Unmarshal([]byte(tt.body), &payload); err != nil {
+				payload = nil
+			}
+			_, raw := redactPayload(payload, []byte(tt.body), keys)
+			if string(raw) != tt.wantRaw {
+				t.Errorf("raw body %s, want %s", raw, tt.wantRaw)
+			}
+		})
+	}
+}
+
+func TestRedactStoredWebhook(t *testing.T) {
+	srv := newTestServer(t, "-redact", "password")
+	mustPost(t, srv, `{"password":"hunter2"`, `[{"password":"hunter2"}]`)
+
+	for _, id := range []int{1, 2} {
+		stored, _ := srv.store.GetByID(id)
+		data, _ := json.Marshal(stored)
+		if strings.Contains(string(data), "hunter2") {
+			t.Errorf("webhook %d still contains the secret: %s", id, data)
+		}
+	}
+}
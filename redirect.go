@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Parse -redirect-events: comma-separated event=URL pairs. Only the first
+// = separates, so URLs may carry query strings.
+func parseRedirectEvents(v string) (map[string]string, error) {
+	redirects := make(map[string]string)
+	for _, pair := range splitList(v) {
+		event, target, ok := strings.Cut(pair, "=")
+		event, target = strings.TrimSpace(event), strings.TrimSpace(target)
+		if !ok || event == "" || target == "" {
+			return nil, fmt.Errorf("invalid redirect entry %q, expected event=URL", pair)
+		}
+		u, err := url.Parse(target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("invalid redirect URL %q for event %q, expected an http or https URL", target, event)
+		}
+		if _, dup := redirects[event]; dup {
+			return nil, fmt.Errorf("event %q has more than one redirect", event)
+		}
+		redirects[event] = target
+	}
+	return redirects, nil
+}
+
+func validRedirectStatus(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// Answer a stored webhook whose event has a -redirect-events entry with a
+// redirect instead of the usual ack. This exists for the odd provider that
+// confirms a subscription handshake by following a redirect; everything
+// else should use the normal ack. Reports whether it redirected.
+func (s *server) redirectAck(w http.ResponseWriter, r *http.Request, event string, webhookID int) bool {
+	target, ok := s.cfg.redirectEvents[event]
+	if !ok || event == "" {
+		return false
+	}
+	s.delayResponse(r)
+	slog.Info("redirecting webhook sender", "webhook_id", webhookID, "event", event, "location", target, "status", s.cfg.redirectStatus)
+	http.Redirect(w, r, target, s.cfg.redirectStatus)
+	return true
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	replayTimeout      = 10 * time.Second
+	replayResponseSize = 4096
+
+	// Largest {"target": ...} request body accepted
+	maxReplayRequestBytes = 64 << 10
+)
+
+// Headers that describe the original connection rather than the webhook and
+// must not be copied onto a replayed request
+var replaySkipHeaders = []string{
+	"Connection", "Content-Length", "Host", "Keep-Alive", "Proxy-Connection",
+	"Te", "Trailer", "Transfer-Encoding", "Upgrade", "Accept-Encoding", "Content-Encoding",
+}
+
+var replayClient = &http.Client{Timeout: replayTimeout}
+
+// POST /webhooks/{id}/replay with {"target": "http://..."}
+func (s *server) replayWebhookHandler(w http.ResponseWriter, r *http.Request, idStr string) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeError(w, "Invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Target string `json:"target"`
+	}
+	err = json.NewDecoder(http.MaxBytesReader(w, r.Body, maxReplayRequestBytes)).Decode(&req)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, fmt.Sprintf("Request body exceeds %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil || req.Target == "" {
+		writeError(w, "Request body must be JSON with a target URL", http.StatusBadRequest)
+		return
+	}
+
+	target, err := url.Parse(req.Target)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		writeError(w, "Invalid target URL", http.StatusBadRequest)
+		return
+	}
+	if !s.replayAllowed(target) {
+		writeError(w, "Target host is not in the replay allowlist", http.StatusForbidden)
+		return
+	}
+
+	webhook, found := s.store.GetByID(id)
+	if !found {
+		writeError(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	status, body, err := replayWebhook(webhook, target.String())
+	if err != nil {
+		slog.Warn("replay failed", "webhook_id", id, "target", target.String(), "error", err)
+		writeError(w, fmt.Sprintf("Replay failed: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	slog.Info("webhook replayed", "webhook_id", id, "target", target.String(), "status", status)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":     id,
+		"target": target.String(),
+		"status": status,
+		"body":   body,
+	})
+}
+
+// With no allowlist configured any target is allowed. Entries match either
+// the target's host:port or its bare hostname.
+func (s *server) replayAllowed(target *url.URL) bool {
+	if len(s.cfg.replayAllowHosts) == 0 {
+		return true
+	}
+	return slices.Contains(s.cfg.replayAllowHosts, target.Host) ||
+		slices.Contains(s.cfg.replayAllowHosts, target.Hostname())
+}
+
+// Re-send the stored body and headers to target, returning the downstream
+// status and the start of its response body
+func replayWebhook(webhook StoredWebhook, target string) (int, string, error) {
+	body := []byte(webhook.RawBody)
+	if webhook.RawBodyEncoding == "base64" {
+		decoded, err := base64.StdEncoding.DecodeString(webhook.RawBody)
+		if err != nil {
+			return 0, "", err
+		}
+		body = decoded
+	}
+
+	method := webhook.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	req, err := http.NewRequest(method, target, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	for name, values := range webhook.Headers {
+		if slices.Contains(replaySkipHeaders, name) {
+			continue
+		}
+		for _, value := range values {
+			if value == redactedValue {
+				continue
+			}
+			req.Header.Add(name, value)
+		}
+	}
+
+	resp, err := replayClient.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, replayResponseSize))
+	if err != nil {
+		return 0, "", err
+	}
+	return resp.StatusCode, strings.ToValidUTF8(string(respBody), "�"), nil
+}
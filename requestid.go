@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"unicode"
+)
+
+// Longest sender-supplied request ID kept; longer ones are replaced
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// Take the sender's -request-id-header value, or make one up, and echo it
+// in the response header. Handlers read it back with requestIDFrom.
+func (s *server) withRequestID(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.cfg.requestIDHeader == "" {
+			next(w, r)
+			return
+		}
+
+		id := r.Header.Get(s.cfg.requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(s.cfg.requestIDHeader, id)
+		next(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	}
+}
+
+// Request ID assigned by withRequestID, or "" when it's disabled
+func requestIDFrom(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+// Sender IDs are echoed into headers and logs, so only short printable
+// ASCII is accepted
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c > unicode.MaxASCII || !unicode.IsPrint(c) {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
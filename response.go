@@ -0,0 +1,25 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Write v as a JSON response, indented when the client asked for it with
+// ?pretty=true or Accept: application/json+pretty
+func writeJSON(w http.ResponseWriter, r *http.Request, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	if wantsPretty(r) {
+		enc.SetIndent("", "  ")
+	}
+	enc.Encode(v)
+}
+
+func wantsPretty(r *http.Request) bool {
+	if r.URL.Query().Get("pretty") == "true" {
+		return true
+	}
+	return strings.Contains(r.Header.Get("Accept"), "application/json+pretty")
+}
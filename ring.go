@@ -0,0 +1,121 @@
+package main
+
+// Smallest buffer a ring allocates on its first push
+const minRingBuffer = 16
+
+// Bounded queue of webhooks, oldest first. The buffer starts empty and
+// doubles as webhooks arrive, up to capacity, so an idle bucket costs next
+// to nothing. Pushing onto a full ring overwrites the oldest slot in place,
+// so adds never allocate once the store is full.
+type webhookRing struct {
+	buf      []StoredWebhook
+	head     int // index of the oldest webhook
+	count    int
+	capacity int
+}
+
+func newWebhookRing(capacity int) *webhookRing {
+	return &webhookRing{capacity: capacity}
+}
+
+func (r *webhookRing) Len() int {
+	return r.count
+}
+
+// Return the i'th webhook, counting from the oldest
+func (r *webhookRing) At(i int) StoredWebhook {
+	return r.buf[(r.head+i)%len(r.buf)]
+}
+
+// Append webhook, returning the webhook it displaced when the ring was full
+func (r *webhookRing) Push(webhook StoredWebhook) (evicted StoredWebhook, full bool) {
+	if r.count == r.capacity {
+		evicted = r.buf[r.head]
+		r.buf[r.head] = webhook
+		r.head = (r.head + 1) % len(r.buf)
+		return evicted, true
+	}
+	if r.count == len(r.buf) {
+		r.reallocate(min(max(2*len(r.buf), minRingBuffer), r.capacity))
+	}
+	r.buf[(r.head+r.count)%len(r.buf)] = webhook
+	r.count++
+	return StoredWebhook{}, false
+}
+
+// Remove and return the oldest webhook. The ring must not be empty.
+func (r *webhookRing) PopOldest() StoredWebhook {
+	webhook := r.buf[r.head]
+	r.buf[r.head] = StoredWebhook{}
+	r.head = (r.head + 1) % len(r.buf)
+	r.count--
+	return webhook
+}
+
+// Remove and return the newest webhook. The ring must not be empty.
+func (r *webhookRing) PopNewest() StoredWebhook {
+	i := (r.head + r.count - 1) % len(r.buf)
+	webhook := r.buf[i]
+	r.buf[i] = StoredWebhook{}
+	r.count--
+	return webhook
+}
+
+// Remove the webhooks for which keep returns false, preserving the order of
+// the rest, and return the removed ones
+func (r *webhookRing) Filter(keep func(StoredWebhook) bool) []StoredWebhook {
+	var removed []StoredWebhook
+	kept := 0
+	for i := 0; i < r.count; i++ {
+		webhook := r.At(i)
+		if !keep(webhook) {
+			removed = append(removed, webhook)
+			continue
+		}
+		r.buf[(r.head+kept)%len(r.buf)] = webhook
+		kept++
+	}
+	// Clear the vacated slots so their payloads can be collected
+	for i := kept; i < r.count; i++ {
+		r.buf[(r.head+i)%len(r.buf)] = StoredWebhook{}
+	}
+	r.count = kept
+	return removed
+}
+
+// Change the capacity, keeping the newest webhooks that fit and returning
+// the ones dropped
+func (r *webhookRing) Resize(capacity int) []StoredWebhook {
+	var dropped []StoredWebhook
+	for r.count > capacity {
+		dropped = append(dropped, r.PopOldest())
+	}
+	r.capacity = capacity
+	r.reallocate(r.count)
+	return dropped
+}
+
+// Reallocate the buffer with room for size webhooks, oldest at index 0.
+// size must be at least count.
+func (r *webhookRing) reallocate(size int) {
+	buf := make([]StoredWebhook, size)
+	for i := 0; i < r.count; i++ {
+		buf[i] = r.At(i)
+	}
+	r.buf, r.head = buf, 0
+}
+
+// Empty the ring, releasing its buffer
+func (r *webhookRing) Reset() {
+	r.buf = nil
+	r.head, r.count = 0, 0
+}
+
+// Copy the webhooks out, oldest first
+func (r *webhookRing) Slice() []StoredWebhook {
+	result := make([]StoredWebhook, r.count)
+	for i := range result {
+		result[i] = r.At(i)
+	}
+	return result
+}
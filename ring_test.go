@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestRingGrowsLazily(t *testing.T) {
+	r := newWebhookRing(1000000)
+	if len(r.buf) != 0 {
+		t.Fatalf("new ring allocated %d slots", len(r.buf))
+	}
+	r.Push(StoredWebhook{ID: 1})
+	if len(r.buf) != minRingBuffer {
+		t.Errorf("after one push the buffer has %d slots, want %d", len(r.buf), minRingBuffer)
+	}
+}
+
+func TestRingOrderAcrossGrowthAndWrap(t *testing.T) {
+	const capacity = 40
+	r := newWebhookRing(capacity)
+	for id := 1; id <= 20; id++ {
+		r.Push(StoredWebhook{ID: id})
+	}
+	// Move the head so the next growth has to unwrap the buffer
+	r.PopOldest()
+	r.PopOldest()
+	for id := 21; id <= 100; id++ {
+		evicted, full := r.Push(StoredWebhook{ID: id})
+		if wantFull := id > 42; full != wantFull || (full && evicted.ID != id-capacity) {
+			t.Fatalf("push %d: evicted %d, full %v", id, evicted.ID, full)
+		}
+	}
+	if len(r.buf) != capacity {
+		t.Errorf("buffer has %d slots, want it capped at %d", len(r.buf), capacity)
+	}
+	for i := 0; i < r.Len(); i++ {
+		if got, want := r.At(i).ID, 61+i; got != want {
+			t.Fatalf("At(%d) = %d, want %d", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// A -reject-when rule: payloads whose value at path equals value are
+// refused with -reject-status instead of being stored
+type rejectRule struct {
+	path  string
+	value string
+}
+
+func (rule rejectRule) String() string {
+	return rule.path + "=" + rule.value
+}
+
+// Log rules in their flag form
+func (rule rejectRule) MarshalText() ([]byte, error) {
+	return []byte(rule.String()), nil
+}
+
+// Match compares the value at the rule's path in its JSON form without
+// quotes, so numbers and booleans are written as they appear in the body
+func (rule rejectRule) Match(payload interface{}) bool {
+	value, ok := lookupPath(payload, rule.path)
+	if !ok {
+		return false
+	}
+	if str, ok := value.(string); ok {
+		return str == rule.value
+	}
+	return fmt.Sprint(value) == rule.value
+}
+
+func parseRejectRules(v string) ([]rejectRule, error) {
+	var rules []rejectRule
+	for _, pair := range splitList(v) {
+		path, value, ok := strings.Cut(pair, "=")
+		path = strings.TrimSpace(path)
+		if !ok || path == "" {
+			return nil, fmt.Errorf("invalid reject rule %q, expected field=value", pair)
+		}
+		rules = append(rules, rejectRule{path: path, value: value})
+	}
+	return rules, nil
+}
+
+// Refuse the request if any payload (one per element for expanded
+// batches) matches a -reject-when rule. Reports whether it was refused.
+func (s *server) applyRejectRules(w http.ResponseWriter, r *http.Request, bucket string, payloads ...interface{}) bool {
+	rule, ok := s.matchingRule(payloads...)
+	if !ok {
+		return false
+	}
+	slog.Info("reject rule fired", "bucket", bucket, "rule", rule.String(), "status", s.cfg.rejectStatus)
+	s.reject(w, r, rejectRuleMatched, "Rejected by rule "+rule.String(), s.cfg.rejectStatus)
+	return true
+}
+
+// First -reject-when rule matching any of the payloads
+func (s *server) matchingRule(payloads ...interface{}) (rejectRule, bool) {
+	for _, rule := range s.cfg.rejectRules {
+		for _, payload := range payloads {
+			if rule.Match(payload) {
+				return rule, true
+			}
+		}
+	}
+	return rejectRule{}, false
+}
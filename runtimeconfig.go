@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+// Change the count cap, evicting the oldest webhooks if the store is now
+// over it. Returns the number evicted.
+func (ws *WebhookStore) SetMaxSize(maxSize int) int {
+	ws.mu.Lock()
+	defer ws.unlock()
+
+	ws.maxSize = maxSize
+	evicted := ws.webhooks.Resize(maxSize)
+	for _, webhook := range evicted {
+		ws.forgetLocked(webhook)
+	}
+	ws.evictedLocked(evictOverflow, evicted...)
+
+	if len(evicted) > 0 {
+		if err := ws.saveLocked(); err != nil {
+			slog.Error("failed to persist webhooks", "error", err)
+		}
+	}
+	return len(evicted)
+}
+
+// PUT /config/max-size[?bucket=<name>] with {"max_size": N}
+func (s *server) maxSizeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	bucket := r.URL.Query().Get("bucket")
+	if bucket == "" {
+		bucket = defaultBucket
+	}
+	store, ok := s.buckets.Lookup(bucket)
+	if !ok {
+		writeError(w, "Bucket not found", http.StatusNotFound)
+		return
+	}
+	resizable, ok := store.(resizableStore)
+	if !ok {
+		writeError(w, "Resizing not supported by this store", http.StatusNotImplemented)
+		return
+	}
+
+	var req struct {
+		MaxSize int `json:"max_size"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&req); err != nil {
+		writeError(w, "Request body must be JSON with a max_size", http.StatusBadRequest)
+		return
+	}
+	if req.MaxSize <= 0 {
+		writeError(w, "max_size must be greater than zero", http.StatusBadRequest)
+		return
+	}
+
+	evicted := resizable.SetMaxSize(req.MaxSize)
+	slog.Info("max size changed", "bucket", bucket, "max_size", req.MaxSize, "evicted", evicted)
+
+	writeJSON(w, r, map[string]interface{}{
+		"bucket":   bucket,
+		"max_size": req.MaxSize,
+		"evicted":  evicted,
+	})
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// A single schema violation, reported back to the sender
+type schemaError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func compileSchema(path string) (*jsonschema.Schema, error) {
+	return jsonschema.Compile(path)
+}
+
+// Validate payload against schema, returning nil when it conforms
+func validatePayload(schema *jsonschema.Schema, payload interface{}) []schemaError {
+	err := schema.Validate(payload)
+	if err == nil {
+		return nil
+	}
+
+	var ve *jsonschema.ValidationError
+	if !errors.As(err, &ve) {
+		return []schemaError{{Field: "", Message: err.Error()}}
+	}
+
+	// Only the leaves describe concrete failures; parents just wrap them
+	var failures []schemaError
+	var collect func(*jsonschema.ValidationError)
+	collect = func(ve *jsonschema.ValidationError) {
+		if len(ve.Causes) == 0 {
+			failures = append(failures, schemaError{Field: ve.InstanceLocation, Message: ve.Message})
+			return
+		}
+		for _, cause := range ve.Causes {
+			collect(cause)
+		}
+	}
+	collect(ve)
+	return failures
+}
+
+// Validate each element of an expanded array, prefixing failure fields with
+// the element's index
+func validateElements(schema *jsonschema.Schema, elements []interface{}) []schemaError {
+	var failures []schemaError
+	for i, element := range elements {
+		for _, failure := range validatePayload(schema, element) {
+			failure.Field = "/" + strconv.Itoa(i) + failure.Field
+			failures = append(failures, failure)
+		}
+	}
+	return failures
+}
+
+func writeSchemaErrors(w http.ResponseWriter, failures []schemaError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  "Payload does not match schema",
+		"code":   http.StatusUnprocessableEntity,
+		"errors": failures,
+	})
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// GET /webhooks/search?q=<text>[&field=<key>]
+func (s *server) searchWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	q := query.Get("q")
+	if q == "" {
+		writeError(w, "Missing search query", http.StatusBadRequest)
+		return
+	}
+	field := query.Get("field")
+
+	matches := filterWebhooks(s.store.GetAll(), func(webhook StoredWebhook) bool {
+		return strings.Contains(searchText(webhook.Payload, field), q)
+	})
+	// GetAll follows -mode, but search results are always newest first
+	slices.SortFunc(matches, func(a, b StoredWebhook) int { return b.ID - a.ID })
+
+	writeJSON(w, r, map[string]interface{}{
+		"count":    len(matches),
+		"webhooks": matches,
+	})
+}
+
+// Text to search in: the whole payload re-serialized as JSON, or a single
+// top-level field when one is given
+func searchText(payload interface{}, field string) string {
+	if field == "" {
+		data, _ := json.Marshal(payload)
+		return string(data)
+	}
+
+	if str := getStringFromPayload(payload, field); str != "" {
+		return str
+	}
+	if payloadMap, ok := payload.(map[string]interface{}); ok {
+		if value, exists := payloadMap[field]; exists {
+			data, _ := json.Marshal(value)
+			return string(data)
+		}
+	}
+	return ""
+}
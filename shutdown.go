@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+// Count requests currently being served so shutdown can report them
+func (s *server) trackInFlight(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.inFlight.Add(1)
+		defer s.inFlight.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Stop accepting connections, wait for in-flight requests up to the
+// configured timeout, then flush the store to disk if persistence is on
+func (s *server) shutdown(httpServer *http.Server) {
+	s.ready.Store(false)
+	slog.Info("shutting down", "in_flight", s.inFlight.Load())
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.shutdownTimeout)
+	defer cancel()
+
+	err := httpServer.Shutdown(ctx)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		slog.Warn("shutdown timed out", "timeout", s.cfg.shutdownTimeout, "in_flight", s.inFlight.Load())
+	case err != nil:
+		slog.Error("shutdown failed", "error", err)
+	default:
+		slog.Info("shutdown completed cleanly")
+	}
+
+	if f, ok := s.store.(flushableStore); ok && s.cfg.persistPath != "" {
+		if err := f.Flush(); err != nil {
+			slog.Error("failed to flush webhooks", "path", s.cfg.persistPath, "error", err)
+		} else {
+			slog.Info("flushed webhooks", "path", s.cfg.persistPath)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+const signatureHeader = "X-Hub-Signature-256"
+
+var (
+	errMissingSignature = errors.New("missing signature")
+	errBadSignature     = errors.New("signature mismatch")
+)
+
+// Checks that a webhook request was signed by the expected sender
+type verifier interface {
+	Verify(header http.Header, body []byte) error
+}
+
+// Generic "sha256=<hex>" HMAC scheme used by GitHub and many others. Any of
+// the secrets may have signed the request, so old and new keys both work
+// while a rotation is under way.
+type hmacVerifier struct {
+	secrets []string
+}
+
+func (v hmacVerifier) Verify(header http.Header, body []byte) error {
+	value := header.Get(signatureHeader)
+	if value == "" {
+		return errMissingSignature
+	}
+	for _, secret := range v.secrets {
+		if verifySignature(secret, body, value) {
+			return nil
+		}
+	}
+	return errBadSignature
+}
+
+// Check a "sha256=<hex>" signature header against the HMAC-SHA256 of body.
+// The comparison is constant-time.
+func verifySignature(secret string, body []byte, header string) bool {
+	sigHex, ok := strings.CutPrefix(header, "sha256=")
+	if !ok {
+		return false
+	}
+	sig, err := hex.DecodeString(sigHex)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(sig, mac.Sum(nil))
+}
+
+// Pick the verifier for each bucket from the configuration. Stripe and
+// Standard Webhooks apply to the buckets listed in -stripe-buckets and
+// -standard-webhooks-buckets, or to every bucket when their list is empty;
+// otherwise the generic HMAC secret applies.
+func newVerifiers(cfg config) (defaultVerifier verifier, bucketVerifiers map[string]verifier) {
+	bucketVerifiers = make(map[string]verifier)
+
+	if cfg.secret != "" {
+		defaultVerifier = hmacVerifier{secrets: splitList(cfg.secret)}
+	}
+
+	if cfg.stripeSecret != "" {
+		stripe := stripeVerifier{secret: cfg.stripeSecret, tolerance: cfg.stripeTolerance}
+		if len(cfg.stripeBuckets) == 0 {
+			defaultVerifier = stripe
+		}
+		for _, name := range cfg.stripeBuckets {
+			bucketVerifiers[name] = stripe
+		}
+	}
+
+	if cfg.standardSecret != "" {
+		// Already validated by loadConfig
+		key, _ := standardWebhooksKey(cfg.standardSecret)
+		standard := standardVerifier{key: key, tolerance: cfg.standardTolerance}
+		if len(cfg.standardBuckets) == 0 {
+			defaultVerifier = standard
+		}
+		for _, name := range cfg.standardBuckets {
+			bucketVerifiers[name] = standard
+		}
+	}
+
+	// Checked by loadConfig not to overlap the Stripe and Standard buckets
+	for name, secrets := range cfg.bucketSecrets {
+		bucketVerifiers[name] = hmacVerifier{secrets: secrets}
+	}
+
+	return defaultVerifier, bucketVerifiers
+}
+
+// Return the verifier for bucket, or nil when no verification is configured
+func (s *server) verifierFor(bucket string) verifier {
+	if v, ok := s.bucketVerifiers[bucket]; ok {
+		return v
+	}
+	return s.defaultVerifier
+}
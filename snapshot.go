@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+// Largest snapshot body POST /admin/restore accepts
+const maxSnapshotBytes = 64 << 20
+
+// Full state of an in-memory store, as served by /admin/snapshot
+type storeSnapshot struct {
+	Webhooks []StoredWebhook `json:"webhooks"`
+	NextID   int             `json:"next_id"`
+	MaxSize  int             `json:"max_size"`
+}
+
+// Check that a snapshot could have come from a store: webhooks oldest
+// first with ascending IDs below next_id, and no more of them than fit
+func (snap storeSnapshot) validate() error {
+	if snap.Webhooks == nil {
+		return fmt.Errorf("snapshot is missing webhooks")
+	}
+	if snap.MaxSize <= 0 {
+		return fmt.Errorf("max_size must be greater than zero")
+	}
+	if len(snap.Webhooks) > snap.MaxSize {
+		return fmt.Errorf("snapshot has %d webhooks but max_size is %d", len(snap.Webhooks), snap.MaxSize)
+	}
+	prev := 0
+	for i, webhook := range snap.Webhooks {
+		if webhook.ID <= prev {
+			return fmt.Errorf("webhook %d has id %d; ids must be positive and ascending", i, webhook.ID)
+		}
+		prev = webhook.ID
+	}
+	if snap.NextID <= prev {
+		return fmt.Errorf("next_id must be greater than the highest webhook id %d", prev)
+	}
+	return nil
+}
+
+func (ws *WebhookStore) Snapshot() storeSnapshot {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	return storeSnapshot{
+		Webhooks: ws.webhooks.Slice(),
+		NextID:   ws.nextID,
+		MaxSize:  ws.maxSize,
+	}
+}
+
+// Replace the whole store with a validated snapshot. Idempotency keys are
+// forgotten, since the snapshot doesn't carry them.
+func (ws *WebhookStore) Restore(snap storeSnapshot) {
+	ws.mu.Lock()
+	defer ws.unlock()
+
+	ws.maxSize = snap.MaxSize
+	ws.webhooks = newWebhookRing(snap.MaxSize)
+	ws.replaceLocked(snap.Webhooks)
+	ws.nextID = snap.NextID
+	ws.resetDedupLocked()
+
+	if err := ws.saveLocked(); err != nil {
+		slog.Error("failed to persist webhooks", "error", err)
+	}
+}
+
+// Look up ?bucket= (default bucket when absent) as a snapshottableStore,
+// writing the error response when that fails
+func (s *server) snapshotStore(w http.ResponseWriter, r *http.Request) (string, snapshottableStore, bool) {
+	bucket := r.URL.Query().Get("bucket")
+	if bucket == "" {
+		bucket = defaultBucket
+	}
+	store, ok := s.buckets.Lookup(bucket)
+	if !ok {
+		writeError(w, "Bucket not found", http.StatusNotFound)
+		return "", nil, false
+	}
+	snapshottable, ok := store.(snapshottableStore)
+	if !ok {
+		writeError(w, "Snapshots not supported by this store", http.StatusNotImplemented)
+		return "", nil, false
+	}
+	return bucket, snapshottable, true
+}
+
+// GET /admin/snapshot[?bucket=<name>]
+func (s *server) snapshotHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	_, store, ok := s.snapshotStore(w, r)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, r, store.Snapshot())
+}
+
+// POST /admin/restore[?bucket=<name>] with a body from /admin/snapshot
+func (s *server) restoreHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	bucket, store, ok := s.snapshotStore(w, r)
+	if !ok {
+		return
+	}
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(http.MaxBytesReader(w, r.Body, maxSnapshotBytes)); err != nil {
+		writeError(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+	dec := json.NewDecoder(&buf)
+	dec.DisallowUnknownFields()
+	var snap storeSnapshot
+	if err := dec.Decode(&snap); err != nil {
+		writeError(w, "Invalid snapshot: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if dec.More() {
+		writeError(w, "Invalid snapshot: unexpected data after the snapshot", http.StatusBadRequest)
+		return
+	}
+	if err := snap.validate(); err != nil {
+		writeError(w, "Invalid snapshot: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	store.Restore(snap)
+	slog.Info("store restored from snapshot", "bucket", bucket, "webhooks", len(snap.Webhooks), "next_id", snap.NextID, "max_size", snap.MaxSize)
+
+	writeJSON(w, r, map[string]interface{}{
+		"message":  "Snapshot restored",
+		"bucket":   bucket,
+		"restored": len(snap.Webhooks),
+		"next_id":  snap.NextID,
+		"max_size": snap.MaxSize,
+	})
+}
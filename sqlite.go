@@ -0,0 +1,373 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS webhooks (
+	id       INTEGER PRIMARY KEY AUTOINCREMENT,
+	bucket   TEXT    NOT NULL,
+	received INTEGER NOT NULL,
+	event    TEXT    NOT NULL DEFAULT '',
+	payload  TEXT,
+	record   TEXT    NOT NULL
+);
+CREATE INDEX IF NOT EXISTS webhooks_bucket_id ON webhooks (bucket, id);
+`
+
+// Open (creating if needed) the SQLite database at path
+func openSQLite(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer; serializing connections avoids
+	// SQLITE_BUSY under concurrent webhooks
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating schema: %w", err)
+	}
+	return db, nil
+}
+
+// Names of the buckets with rows in db, so they can be registered at
+// startup rather than on their next POST
+func sqliteBuckets(db *sql.DB) ([]string, error) {
+	rows, err := db.Query(`SELECT DISTINCT bucket FROM webhooks ORDER BY bucket`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// SQLite-backed Store. All buckets share one webhooks table, so IDs come
+// from a single AUTOINCREMENT sequence and are never reused, even after
+// Clear.
+type SQLiteStore struct {
+	db       *sql.DB
+	bucket   string
+	maxSize  int
+	maxBytes int64
+	mode     retentionMode
+	now      func() time.Time
+
+	received atomic.Int64
+
+	// Told about rows dropped by the caps or the TTL; nil for none
+	onEvict evictFunc
+}
+
+func NewSQLiteStore(db *sql.DB, bucket string, maxSize int, maxBytes int64, mode retentionMode) *SQLiteStore {
+	return &SQLiteStore{
+		db:       db,
+		bucket:   bucket,
+		maxSize:  maxSize,
+		maxBytes: maxBytes,
+		mode:     mode,
+		now:      time.Now,
+	}
+}
+
+// Insert webhook and delete the oldest rows beyond maxSize or, when set,
+// maxBytes of stored records. As in WebhookStore the newest row is always
+// kept.
+func (ss *SQLiteStore) Add(webhook StoredWebhook) StoredWebhook {
+	stored, _, err := ss.AddDurable(webhook, "")
+	if err != nil {
+		slog.Error("failed to store webhook in SQLite", "bucket", ss.bucket, "error", err)
+	}
+	return stored
+}
+
+// Add, returning the error when the insert fails. The store isn't
+// idempotent, so key is ignored.
+func (ss *SQLiteStore) AddDurable(webhook StoredWebhook, key string) (StoredWebhook, bool, error) {
+	webhook.ID = 0
+	webhook.Received = ss.now()
+	webhook.Hash = payloadHash(webhook)
+
+	if err := ss.insert(&webhook); err != nil {
+		return webhook, false, err
+	}
+	ss.received.Add(1)
+	return webhook, false, nil
+}
+
+func (ss *SQLiteStore) insert(webhook *StoredWebhook) error {
+	record, err := json.Marshal(webhook)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(webhook.Payload)
+	if err != nil {
+		return err
+	}
+
+	tx, err := ss.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(
+		`INSERT INTO webhooks (bucket, received, event, payload, record) VALUES (?, ?, ?, ?, ?)`,
+		ss.bucket, webhook.Received.UnixNano(), webhookEvent(*webhook), string(payload), string(record),
+	)
+	if err != nil {
+		return err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	_, evicted, err := ss.deleteReturning(tx,
+		`DELETE FROM webhooks WHERE bucket = ? AND id NOT IN (
+			SELECT id FROM webhooks WHERE bucket = ? ORDER BY id DESC LIMIT ?
+		)`,
+		ss.bucket, ss.bucket, ss.maxSize,
+	)
+	if err != nil {
+		return err
+	}
+
+	if ss.maxBytes > 0 {
+		_, overBytes, err := ss.deleteReturning(tx,
+			`DELETE FROM webhooks WHERE id IN (
+				SELECT id FROM (
+					SELECT id, SUM(length(CAST(record AS BLOB))) OVER (ORDER BY id DESC) AS total
+					FROM webhooks WHERE bucket = ?
+				) WHERE total > ? AND id < ?
+			)`,
+			ss.bucket, ss.maxBytes, id,
+		)
+		if err != nil {
+			return err
+		}
+		evicted = append(evicted, overBytes...)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	webhook.ID = int(id)
+	ss.evicted(evictOverflow, evicted)
+	return nil
+}
+
+// Run a DELETE, returning how many rows it removed and, when there's an
+// eviction hook, the deleted webhooks. Without one the rows aren't read
+// back at all.
+func (ss *SQLiteStore) deleteReturning(q sqlQueryer, query string, args ...any) (int, []StoredWebhook, error) {
+	if ss.onEvict == nil {
+		res, err := q.Exec(query, args...)
+		if err != nil {
+			return 0, nil, err
+		}
+		n, _ := res.RowsAffected()
+		return int(n), nil, nil
+	}
+
+	rows, err := q.Query(query+` RETURNING id, received, record`, args...)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
+
+	var deleted []StoredWebhook
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return 0, nil, err
+		}
+		deleted = append(deleted, webhook)
+	}
+	return len(deleted), deleted, rows.Err()
+}
+
+// Report evicted rows to the hook, oldest first, if there are any
+func (ss *SQLiteStore) evicted(reason string, webhooks []StoredWebhook) {
+	if ss.onEvict == nil || len(webhooks) == 0 {
+		return
+	}
+	slices.SortFunc(webhooks, func(a, b StoredWebhook) int { return a.ID - b.ID })
+	ss.onEvict(reason, webhooks...)
+}
+
+// Same ordering as WebhookStore.GetAll
+func (ss *SQLiteStore) GetAll() []StoredWebhook {
+	return ss.getAll(ss.db)
+}
+
+// Read within one transaction so the list and next ID agree
+func (ss *SQLiteStore) GetAllWithCapacity() (webhooks []StoredWebhook, nextID, maxSize int) {
+	tx, err := ss.db.Begin()
+	if err != nil {
+		slog.Error("failed to query SQLite", "bucket", ss.bucket, "error", err)
+		return []StoredWebhook{}, ss.NextID(), ss.maxSize
+	}
+	defer tx.Rollback()
+
+	return ss.getAll(tx), ss.nextID(tx), ss.maxSize
+}
+
+// Implemented by both *sql.DB and *sql.Tx
+type sqlQueryer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func (ss *SQLiteStore) getAll(q sqlQueryer) []StoredWebhook {
+	order := "DESC"
+	if ss.mode == modeFIFO {
+		order = "ASC"
+	}
+
+	rows, err := q.Query(
+		`SELECT id, received, record FROM webhooks WHERE bucket = ? ORDER BY id `+order,
+		ss.bucket,
+	)
+	if err != nil {
+		slog.Error("failed to query SQLite", "bucket", ss.bucket, "error", err)
+		return []StoredWebhook{}
+	}
+	defer rows.Close()
+
+	webhooks := make([]StoredWebhook, 0)
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			slog.Error("failed to read webhook from SQLite", "bucket", ss.bucket, "error", err)
+			continue
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	if err := rows.Err(); err != nil {
+		slog.Error("failed to query SQLite", "bucket", ss.bucket, "error", err)
+	}
+	return webhooks
+}
+
+func (ss *SQLiteStore) GetByID(id int) (StoredWebhook, bool) {
+	row := ss.db.QueryRow(
+		`SELECT id, received, record FROM webhooks WHERE bucket = ? AND id = ?`,
+		ss.bucket, id,
+	)
+	webhook, err := scanWebhook(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return StoredWebhook{}, false
+	}
+	if err != nil {
+		slog.Error("failed to read webhook from SQLite", "bucket", ss.bucket, "webhook_id", id, "error", err)
+		return StoredWebhook{}, false
+	}
+	return webhook, true
+}
+
+func (ss *SQLiteStore) DeleteByID(id int) bool {
+	res, err := ss.db.Exec(`DELETE FROM webhooks WHERE bucket = ? AND id = ?`, ss.bucket, id)
+	if err != nil {
+		slog.Error("failed to delete webhook from SQLite", "bucket", ss.bucket, "webhook_id", id, "error", err)
+		return false
+	}
+	n, _ := res.RowsAffected()
+	return n > 0
+}
+
+func (ss *SQLiteStore) Clear() int {
+	res, err := ss.db.Exec(`DELETE FROM webhooks WHERE bucket = ?`, ss.bucket)
+	if err != nil {
+		slog.Error("failed to clear SQLite bucket", "bucket", ss.bucket, "error", err)
+		return 0
+	}
+	n, _ := res.RowsAffected()
+	return int(n)
+}
+
+func (ss *SQLiteStore) Len() int {
+	var n int
+	if err := ss.db.QueryRow(`SELECT COUNT(*) FROM webhooks WHERE bucket = ?`, ss.bucket).Scan(&n); err != nil {
+		slog.Error("failed to count SQLite webhooks", "bucket", ss.bucket, "error", err)
+	}
+	return n
+}
+
+func (ss *SQLiteStore) RemoveOlderThan(cutoff time.Time) int {
+	n, expired, err := ss.deleteReturning(ss.db,
+		`DELETE FROM webhooks WHERE bucket = ? AND received < ?`,
+		ss.bucket, cutoff.UnixNano(),
+	)
+	if err != nil {
+		slog.Error("failed to expire SQLite webhooks", "bucket", ss.bucket, "error", err)
+		return 0
+	}
+	ss.evicted(evictTTL, expired)
+	return n
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// The id and received columns are authoritative; the record holds the rest
+func scanWebhook(row rowScanner) (StoredWebhook, error) {
+	var id int
+	var received int64
+	var record string
+	if err := row.Scan(&id, &received, &record); err != nil {
+		return StoredWebhook{}, err
+	}
+
+	var webhook StoredWebhook
+	if err := json.Unmarshal([]byte(record), &webhook); err != nil {
+		return StoredWebhook{}, err
+	}
+	webhook.ID = id
+	webhook.Received = time.Unix(0, received)
+	return webhook, nil
+}
+
+func (ss *SQLiteStore) MaxSize() int {
+	return ss.maxSize
+}
+
+func (ss *SQLiteStore) Received() int {
+	return int(ss.received.Load())
+}
+
+// Next value of the shared AUTOINCREMENT sequence
+func (ss *SQLiteStore) NextID() int {
+	return ss.nextID(ss.db)
+}
+
+func (ss *SQLiteStore) nextID(q sqlQueryer) int {
+	var seq int
+	err := q.QueryRow(`SELECT seq FROM sqlite_sequence WHERE name = 'webhooks'`).Scan(&seq)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		slog.Error("failed to query SQLite", "bucket", ss.bucket, "error", err)
+	}
+	return seq + 1
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSQLiteBucketsSurviveReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhooks.db")
+	db, err := openSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	NewSQLiteStore(db, "github", 10, 0, modeLIFO).Add(StoredWebhook{Payload: map[string]interface{}{"n": 1}})
+	NewSQLiteStore(db, "stripe", 10, 0, modeLIFO).Add(StoredWebhook{Payload: map[string]interface{}{"n": 2}})
+	db.Close()
+
+	db, err = openSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	names, err := sqliteBuckets(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"github", "stripe"}; !slices.Equal(names, want) {
+		t.Errorf("buckets after reopen = %v, want %v", names, want)
+	}
+}
+
+// -db has no write queue; asking for one is a mistake, not a no-op
+func TestPersistQueueRequiresPersist(t *testing.T) {
+	for _, args := range [][]string{
+		{"-db", "webhooks.db", "-persist-queue", "128"},
+		{"-db", "webhooks.db", "-persist-overflow", "block"},
+	} {
+		if _, err := loadConfig(args); err == nil {
+			t.Errorf("loadConfig(%q) accepted a persist queue setting with -db", args)
+		}
+	}
+	if _, err := loadConfig([]string{"-db", "webhooks.db"}); err != nil {
+		t.Errorf("-db with the default queue settings: %v", err)
+	}
+	if _, err := loadConfig([]string{"-persist", "webhooks.json", "-persist-queue", "128"}); err != nil {
+		t.Errorf("-persist with -persist-queue: %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The example from the Standard Webhooks specification
+const (
+	standardFixtureSecret    = "whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw"
+	standardFixtureID        = "msg_p5jXN8AQM9LWM0D4loKWxJek"
+	standardFixtureTimestamp = "1614265330"
+	standardFixturePayload   = `{"test": 2432232314}`
+	standardFixtureSignature = "v1,g0hM9SsE+OTPJTGt/tmIKtSyZlE3uFJELVlNIOLJ1OE="
+)
+
+func TestStandardVerifier(t *testing.T) {
+	key, err := standardWebhooksKey(standardFixtureSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedAt := time.Unix(1614265330, 0)
+
+	tests := []struct {
+		name      string
+		id        string
+		timestamp string
+		signature string
+		body      string
+		now       time.Time
+		wantErr   error // nil for success; errAny for any error
+	}{
+		{"valid", standardFixtureID, standardFixtureTimestamp, standardFixtureSignature, standardFixturePayload, signedAt.Add(time.Minute), nil},
+		{"valid among other signatures", standardFixtureID, standardFixtureTimestamp, "v1,bm90IGl0 v2,abc " + standardFixtureSignature, standardFixturePayload, signedAt, nil},
+		{"expired", standardFixtureID, standardFixtureTimestamp, standardFixtureSignature, standardFixturePayload, signedAt.Add(6 * time.Minute), errStaleTimestamp},
+		{"from the future", standardFixtureID, standardFixtureTimestamp, standardFixtureSignature, standardFixturePayload, signedAt.Add(-6 * time.Minute), errStaleTimestamp},
+		{"tampered body", standardFixtureID, standardFixtureTimestamp, standardFixtureSignature, `{"test": 2432232315}`, signedAt, errBadSignature},
+		{"tampered id", "msg_other", standardFixtureTimestamp, standardFixtureSignature, standardFixturePayload, signedAt, errBadSignature},
+		{"tampered timestamp", standardFixtureID, "1614265331", standardFixtureSignature, standardFixturePayload, signedAt, errBadSignature},
+		{"tampered signature", standardFixtureID, standardFixtureTimestamp, strings.Replace(standardFixtureSignature, "g0h", "g1h", 1), standardFixturePayload, signedAt, errBadSignature},
+		{"missing signature", standardFixtureID, standardFixtureTimestamp, "", standardFixturePayload, signedAt, errMissingSignature},
+		{"missing id", "", standardFixtureTimestamp, standardFixtureSignature, standardFixturePayload, signedAt, errAny},
+		{"bad timestamp", standardFixtureID, "soon", standardFixtureSignature, standardFixturePayload, signedAt, errAny},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := standardVerifier{
+				key:       key,
+				tolerance: 5 * time.Minute,
+				now:       func() time.Time { return tt.now },
+			}
+			header := http.Header{}
+			for name, value := range map[string]string{
+				standardIDHeader:        tt.id,
+				standardTimestampHeader: tt.timestamp,
+				standardSignatureHeader: tt.signature,
+			} {
+				if value != "" {
+					header.Set(name, value)
+				}
+			}
+
+			err := v.Verify(header, []byte(tt.body))
+			checkVerifyError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestStandardWebhooksKey(t *testing.T) {
+	withPrefix, err := standardWebhooksKey(standardFixtureSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bare, err := standardWebhooksKey(strings.TrimPrefix(standardFixtureSecret, standardSecretPrefix))
+	if err != nil || string(bare) != string(withPrefix) {
+		t.Errorf("bare base64 secret: key %x, err %v; want %x", bare, err, withPrefix)
+	}
+	if _, err := standardWebhooksKey("whsec_not base64!"); err == nil {
+		t.Error("malformed secret accepted")
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// Event key used in stats for webhooks without an event
+const unknownEvent = "unknown"
+
+type webhookStats struct {
+	Stored   int            `json:"stored"`
+	Received *int           `json:"received_total,omitempty"`
+	Events   map[string]int `json:"events"`
+	Oldest   *time.Time     `json:"oldest,omitempty"`
+	Newest   *time.Time     `json:"newest,omitempty"`
+	NextID   *int           `json:"next_id,omitempty"`
+}
+
+// GET /webhooks/stats
+func (s *server) statsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, r, collectStats(s.store))
+}
+
+func collectStats(store Store) webhookStats {
+	if indexed, ok := store.(eventIndexedStore); ok {
+		return indexed.Stats()
+	}
+
+	webhooks := store.GetAll()
+	stats := webhookStats{
+		Stored: len(webhooks),
+		Events: make(map[string]int),
+	}
+
+	for i := range webhooks {
+		event := webhookEvent(webhooks[i])
+		if event == "" {
+			event = unknownEvent
+		}
+		stats.Events[event]++
+
+		received := &webhooks[i].Received
+		if stats.Oldest == nil || received.Before(*stats.Oldest) {
+			stats.Oldest = received
+		}
+		if stats.Newest == nil || received.After(*stats.Newest) {
+			stats.Newest = received
+		}
+	}
+
+	if counting, ok := store.(countingStore); ok {
+		received, nextID := counting.Received(), counting.NextID()
+		stats.Received, stats.NextID = &received, &nextID
+	}
+	return stats
+}
@@ -0,0 +1,128 @@
+package main
+
+import "time"
+
+// Storage backend used by the HTTP handlers. WebhookStore is the default
+// in-memory implementation.
+type Store interface {
+	// Store webhook, assigning its ID and received time, and return the
+	// stored copy
+	Add(webhook StoredWebhook) StoredWebhook
+	GetAll() []StoredWebhook
+	GetByID(id int) (StoredWebhook, bool)
+	DeleteByID(id int) bool
+	// Remove everything, returning how many webhooks were removed
+	Clear() int
+	Len() int
+}
+
+// Optional capabilities a Store may provide. Features that depend on one
+// are unavailable, or fall back to the core methods, when the backend
+// doesn't implement it.
+
+// Used by /webhooks/stream
+type subscribableStore interface {
+	Subscribe() ([]StoredWebhook, <-chan StoredWebhook, func())
+}
+
+// Used by -dedup-header
+type idempotentStore interface {
+	AddIdempotent(webhook StoredWebhook, key string) (StoredWebhook, bool)
+}
+
+// Used by /webhooks/export to avoid copying the whole store
+type iterableStore interface {
+	Each(ascending bool, fn func(StoredWebhook) error) error
+}
+
+// Used by -ttl
+type expiringStore interface {
+	RemoveOlderThan(cutoff time.Time) int
+}
+
+// Used on shutdown to write pending state to durable storage
+type flushableStore interface {
+	Flush() error
+}
+
+// Used by /webhooks/stats
+type countingStore interface {
+	// Webhooks added since startup, whether or not still stored
+	Received() int
+	// ID the next stored webhook will get
+	NextID() int
+}
+
+// Used by /buckets to report each bucket's limit
+type boundedStore interface {
+	MaxSize() int
+}
+
+// Used by PUT /config/max-size
+type resizableStore interface {
+	// Change the count cap, returning how many webhooks were evicted
+	SetMaxSize(maxSize int) int
+}
+
+// Used by the webhook handlers to answer 503 rather than acknowledge a
+// webhook that didn't reach durable storage
+type durableStore interface {
+	// Add, deduplicating on key when the store is idempotent, returning
+	// the error from a failed synchronous write. The webhook isn't stored
+	// when err is set.
+	AddDurable(webhook StoredWebhook, key string) (stored StoredWebhook, duplicate bool, err error)
+}
+
+// Used by /webhooks/latest
+type latestStore interface {
+	// Newest webhook, restricted to event unless it's empty
+	Latest(event string) (StoredWebhook, bool)
+}
+
+// Used by /admin/snapshot and /admin/restore
+type snapshottableStore interface {
+	Snapshot() storeSnapshot
+	// Replace the store's contents; snap must already be validated
+	Restore(snap storeSnapshot)
+}
+
+// Used by GET /webhooks to report how full the store is
+type capacityStore interface {
+	// GetAll together with the next ID to be assigned and the count cap,
+	// read consistently with each other
+	GetAllWithCapacity() (webhooks []StoredWebhook, nextID, maxSize int)
+}
+
+// Used by ?event= filtering and /webhooks/stats to avoid scanning the store
+type eventIndexedStore interface {
+	// GetAllWithCapacity restricted to one event (or event prefix), with
+	// stored counting the whole store
+	GetByEventWithCapacity(event string, prefix bool) (webhooks []StoredWebhook, stored, nextID, maxSize int)
+	Stats() webhookStats
+}
+
+var (
+	_ Store              = (*WebhookStore)(nil)
+	_ subscribableStore  = (*WebhookStore)(nil)
+	_ idempotentStore    = (*WebhookStore)(nil)
+	_ iterableStore      = (*WebhookStore)(nil)
+	_ expiringStore      = (*WebhookStore)(nil)
+	_ flushableStore     = (*WebhookStore)(nil)
+	_ countingStore      = (*WebhookStore)(nil)
+	_ capacityStore      = (*WebhookStore)(nil)
+	_ boundedStore       = (*WebhookStore)(nil)
+	_ resizableStore     = (*WebhookStore)(nil)
+	_ eventIndexedStore  = (*WebhookStore)(nil)
+	_ snapshottableStore = (*WebhookStore)(nil)
+	_ latestStore        = (*WebhookStore)(nil)
+	_ durableStore       = (*WebhookStore)(nil)
+)
+
+var (
+	_ Store         = (*SQLiteStore)(nil)
+	_ expiringStore = (*SQLiteStore)(nil)
+	_ countingStore = (*SQLiteStore)(nil)
+	_ capacityStore = (*SQLiteStore)(nil)
+	_ boundedStore  = (*SQLiteStore)(nil)
+	_ durableStore  = (*SQLiteStore)(nil)
+)
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Minimal Store with none of the optional capabilities, standing in for
+// a third-party backend
+type fakeStore struct {
+	mu       sync.Mutex
+	webhooks []StoredWebhook // oldest first
+	nextID   int
+	adds     int
+}
+
+func (fs *fakeStore) Add(webhook StoredWebhook) StoredWebhook {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.nextID++
+	fs.adds++
+	webhook.ID = fs.nextID
+	webhook.Received = time.Now()
+	fs.webhooks = append(fs.webhooks, webhook)
+	return webhook
+}
+
+func (fs *fakeStore) GetAll() []StoredWebhook {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	all := slices.Clone(fs.webhooks)
+	slices.Reverse(all)
+	return all
+}
+
+func (fs *fakeStore) GetByID(id int) (StoredWebhook, bool) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	for _, webhook := range fs.webhooks {
+		if webhook.ID == id {
+			return webhook, true
+		}
+	}
+	return StoredWebhook{}, false
+}
+
+func (fs *fakeStore) DeleteByID(id int) bool {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	n := len(fs.webhooks)
+	fs.webhooks = slices.DeleteFunc(fs.webhooks, func(webhook StoredWebhook) bool { return webhook.ID == id })
+	return len(fs.webhooks) < n
+}
+
+func (fs *fakeStore) Clear() int {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	n := len(fs.webhooks)
+	fs.webhooks = nil
+	return n
+}
+
+func (fs *fakeStore) Len() int {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return len(fs.webhooks)
+}
+
+var _ Store = (*fakeStore)(nil)
+
+// The handlers only need the Store interface, falling back where an
+// optional capability is missing
+func TestSwappableStore(t *testing.T) {
+	srv := newTestServer(t)
+	fake := &fakeStore{}
+	srv.store = fake
+	srv.buckets = newBucketRegistry(fake, func(string) Store { return &fakeStore{} }, 0)
+	srv.metrics = newMetrics(prometheus.NewRegistry(), fake, srv.cfg.metricsLabelLimit)
+
+	mustPost(t, srv, `{"n":1}`, `{"n":2}`, `{"n":3}`)
+	if fake.adds != 3 {
+		t.Fatalf("fake store saw %d adds, want 3", fake.adds)
+	}
+	if got := listedIDs(t, srv); !slices.Equal(got, []int{3, 2, 1}) {
+		t.Errorf("listed ids = %v, want [3 2 1]", got)
+	}
+
+	rec := serve(srv, http.MethodGet, "/webhooks/2", "", nil)
+	var webhook StoredWebhook
+	decodeResponse(t, rec, &webhook)
+	if rec.Code != http.StatusOK || webhook.ID != 2 {
+		t.Errorf("GET /webhooks/2: status %d, id %d", rec.Code, webhook.ID)
+	}
+
+	if rec := serve(srv, http.MethodDelete, "/webhooks/2", "", nil); rec.Code != http.StatusOK {
+		t.Errorf("DELETE /webhooks/2: status %d", rec.Code)
+	}
+	if rec := serve(srv, http.MethodGet, "/webhooks/export", "", nil); rec.Code != http.StatusOK {
+		t.Errorf("export without iterableStore: status %d", rec.Code)
+	}
+	if rec := serve(srv, http.MethodPost, "/webhooks/clear", "", nil); rec.Code != http.StatusOK {
+		t.Errorf("POST /webhooks/clear: status %d", rec.Code)
+	}
+	if fake.Len() != 0 {
+		t.Errorf("%d webhooks left after clear", fake.Len())
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	subscriberBuffer = 16
+	streamKeepAlive  = 30 * time.Second
+)
+
+// Register for newly stored webhooks. The snapshot of the current stack and
+// the subscription are taken under the same lock so nothing is missed or
+// duplicated between them. Call cancel when done to release the channel.
+func (ws *WebhookStore) Subscribe() ([]StoredWebhook, <-chan StoredWebhook, func()) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	ch := make(chan StoredWebhook, subscriberBuffer)
+	ws.subscribers[ch] = struct{}{}
+
+	cancel := func() {
+		ws.mu.Lock()
+		defer ws.mu.Unlock()
+		delete(ws.subscribers, ch)
+	}
+	return ws.orderedLocked(), ch, cancel
+}
+
+// Send webhook to all subscribers. Callers must hold ws.mu. Slow subscribers
+// whose buffer is full miss the update rather than blocking Add.
+func (ws *WebhookStore) broadcastLocked(webhook StoredWebhook) {
+	for ch := range ws.subscribers {
+		select {
+		case ch <- webhook:
+		default:
+			slog.Warn("dropping webhook for slow stream subscriber", "webhook_id", webhook.ID)
+		}
+	}
+}
+
+// Server-Sent Events stream. Sends a "snapshot" event with the current stack,
+// then one data event per newly stored webhook.
+func (s *server) streamWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	sub, ok := s.store.(subscribableStore)
+	if !ok {
+		writeError(w, "Streaming not supported by this store", http.StatusNotImplemented)
+		return
+	}
+
+	snapshot, updates, cancel := sub.Subscribe()
+	defer cancel()
+
+	// The stream outlives -write-timeout by design
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	if err := writeEvent(w, "snapshot", snapshot); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case webhook := <-updates:
+			if err := writeEvent(w, "", webhook); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if event != "" {
+		if _, err := fmt.Fprintf(w, "event: %s\n", event); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "data: %s\n\n", data)
+	return err
+}
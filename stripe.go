@@ -0,0 +1,80 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const stripeSignatureHeader = "Stripe-Signature"
+
+var errStaleTimestamp = errors.New("timestamp outside tolerance")
+
+// Stripe's timestamped scheme: the header carries "t=<unix>,v1=<hex>,..."
+// and each v1 is an HMAC-SHA256 of "<t>.<body>"
+type stripeVerifier struct {
+	secret    string
+	tolerance time.Duration
+	now       func() time.Time
+}
+
+func (v stripeVerifier) Verify(header http.Header, body []byte) error {
+	value := header.Get(stripeSignatureHeader)
+	if value == "" {
+		return errMissingSignature
+	}
+
+	var timestamp string
+	var signatures [][]byte
+	for _, part := range strings.Split(value, ",") {
+		key, val, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp = val
+		case "v1":
+			if sig, err := hex.DecodeString(val); err == nil {
+				signatures = append(signatures, sig)
+			}
+		}
+	}
+	if timestamp == "" || len(signatures) == 0 {
+		return fmt.Errorf("malformed %s header", stripeSignatureHeader)
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("malformed %s timestamp %q", stripeSignatureHeader, timestamp)
+	}
+	now := time.Now
+	if v.now != nil {
+		now = v.now
+	}
+	if v.tolerance > 0 {
+		age := now().Sub(time.Unix(unix, 0))
+		if age > v.tolerance || age < -v.tolerance {
+			return fmt.Errorf("%w: signed %s ago, tolerance %s", errStaleTimestamp, age.Round(time.Second), v.tolerance)
+		}
+	}
+
+	mac := hmac.New(sha256.New, []byte(v.secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	expected := mac.Sum(nil)
+
+	for _, sig := range signatures {
+		if hmac.Equal(sig, expected) {
+			return nil
+		}
+	}
+	return errBadSignature
+}
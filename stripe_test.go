@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Signed as Stripe documents: HMAC-SHA256 of "<t>.<payload>" with the
+// endpoint secret, hex-encoded as v1
+const (
+	stripeFixtureSecret    = "whsec_test_secret"
+	stripeFixtureTimestamp = 1700000000
+	stripeFixturePayload   = `{"id":"evt_1NG8Du2eZvKYlo2CUI79vXWy","object":"event","type":"payment_intent.succeeded"}`
+	stripeFixtureSignature = "1ee8dd662a91c944c5fc90522bd8a6c2fa5ad2f05377bafef52d694497464c9a"
+)
+
+func TestStripeVerifier(t *testing.T) {
+	signedAt := time.Unix(stripeFixtureTimestamp, 0)
+	valid := "t=1700000000,v1=" + stripeFixtureSignature
+
+	tests := []struct {
+		name    string
+		header  string
+		body    string
+		now     time.Time
+		wantErr error // nil for success; errAny for any error
+	}{
+		{"valid", valid, stripeFixturePayload, signedAt.Add(time.Minute), nil},
+		{"valid with a rotated-out v1 alongside", "t=1700000000,v1=" + strings.Repeat("00", 32) + ",v1=" + stripeFixtureSignature + ",v0=abc", stripeFixturePayload, signedAt, nil},
+		{"expired", valid, stripeFixturePayload, signedAt.Add(6 * time.Minute), errStaleTimestamp},
+		{"from the future", valid, stripeFixturePayload, signedAt.Add(-6 * time.Minute), errStaleTimestamp},
+		{"tampered body", valid, strings.Replace(stripeFixturePayload, "succeeded", "failed", 1), signedAt, errBadSignature},
+		{"tampered signature", "t=1700000000,v1=" + strings.Replace(stripeFixtureSignature, "1e", "1f", 1), stripeFixturePayload, signedAt, errBadSignature},
+		{"tampered timestamp", "t=1700000001,v1=" + stripeFixtureSignature, stripeFixturePayload, signedAt, errBadSignature},
+		{"missing header", "", stripeFixturePayload, signedAt, errMissingSignature},
+		{"no v1", "t=1700000000,v0=" + stripeFixtureSignature, stripeFixturePayload, signedAt, errAny},
+		{"bad timestamp", "t=soon,v1=" + stripeFixtureSignature, stripeFixturePayload, signedAt, errAny},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := stripeVerifier{
+				secret:    stripeFixtureSecret,
+				tolerance: 5 * time.Minute,
+				now:       func() time.Time { return tt.now },
+			}
+			header := http.Header{}
+			if tt.header != "" {
+				header.Set(stripeSignatureHeader, tt.header)
+			}
+
+			err := v.Verify(header, []byte(tt.body))
+			checkVerifyError(t, err, tt.wantErr)
+		})
+	}
+}
+
+// Stand-in for "some error" in verifier test tables
+var errAny = errors.New("any error")
+
+func checkVerifyError(t *testing.T, err, want error) {
+	t.Helper()
+	switch {
+	case want == nil && err != nil:
+		t.Errorf("Verify: %v, want success", err)
+	case want == errAny && err == nil:
+		t.Error("Verify succeeded, want an error")
+	case want != nil && want != errAny && !errors.Is(err, want):
+		t.Errorf("Verify: %v, want %v", err, want)
+	}
+}
+
+func TestStripeWebhook(t *testing.T) {
+	srv := newTestServer(t, "-stripe-secret", stripeFixtureSecret)
+	header := http.Header{
+		"Content-Type":        {"application/json"},
+		stripeSignatureHeader: {"t=1700000000,v1=" + stripeFixtureSignature},
+	}
+
+	// The fixture was signed long ago, so the real clock rejects it
+	rec := serve(srv, http.MethodPost, "/webhook", stripeFixturePayload, header)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expired signature: status %d, want 401", rec.Code)
+	}
+	if srv.store.Len() != 0 {
+		t.Error("webhook with an expired signature was stored")
+	}
+
+	srv.defaultVerifier = stripeVerifier{
+		secret:    stripeFixtureSecret,
+		tolerance: srv.cfg.stripeTolerance,
+		now:       func() time.Time { return time.Unix(stripeFixtureTimestamp, 0) },
+	}
+	rec = serve(srv, http.MethodPost, "/webhook", stripeFixturePayload, header)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("valid signature: status %d, body %s", rec.Code, rec.Body)
+	}
+	if stored, _ := srv.store.GetByID(1); !stored.Verified {
+		t.Error("webhook not marked verified")
+	}
+}
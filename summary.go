@@ -0,0 +1,66 @@
+package main
+
+import (
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// Look up a dot-separated path such as "repository.name" in a decoded
+// payload. Numeric segments index into arrays, and form fields resolve to
+// their first value.
+func lookupPath(payload interface{}, path string) (interface{}, bool) {
+	value := payload
+	for _, segment := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			next, ok := v[segment]
+			if !ok {
+				return nil, false
+			}
+			value = next
+		case map[string][]string:
+			values := v[segment]
+			if len(values) == 0 {
+				return nil, false
+			}
+			value = values[0]
+		case []interface{}:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			value = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// Values of the -summary-fields paths present in payload, or nil when no
+// fields are configured
+func summarize(payload interface{}, fields []string) map[string]interface{} {
+	if len(fields) == 0 {
+		return nil
+	}
+	summary := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		if value, ok := lookupPath(payload, field); ok {
+			summary[field] = value
+		}
+	}
+	return summary
+}
+
+// Log group for a summary, keeping the configured field order. Empty
+// groups are dropped by slog, so this is a no-op without -summary-fields.
+func summaryAttr(summary map[string]interface{}, fields []string) slog.Attr {
+	var attrs []any
+	for _, field := range fields {
+		if value, ok := summary[field]; ok {
+			attrs = append(attrs, slog.Any(field, value))
+		}
+	}
+	return slog.Group("summary", attrs...)
+}
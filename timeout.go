@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Apply -handler-timeout to every request except the long-lived streams,
+// answering 503 with a JSON error when a handler runs over. Paths are
+// relative to -base-path.
+func (s *server) withTimeout(next http.Handler) http.Handler {
+	if s.cfg.handlerTimeout <= 0 {
+		return next
+	}
+
+	body, _ := json.Marshal(errorResponse{Error: "Request timed out", Code: http.StatusServiceUnavailable})
+	timeout := http.TimeoutHandler(next, s.cfg.handlerTimeout, string(body))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isLongLived(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		timeout.ServeHTTP(timeoutWriter{w}, r)
+	})
+}
+
+// Streaming endpoints, which TimeoutHandler can't serve since it buffers
+// the response and hides Flusher and Hijacker. The exports stream the whole
+// store as they go rather than building it in memory, so they're exempt
+// even without ?follow=true; -write-timeout still bounds them.
+func isLongLived(r *http.Request) bool {
+	switch r.URL.Path {
+	case "/webhooks/stream", "/ws", "/webhooks/export", "/webhooks/export.csv":
+		return true
+	}
+	return false
+}
+
+// Labels TimeoutHandler's 503 body as JSON. Handlers that set their own
+// Content-Type are unaffected.
+type timeoutWriter struct {
+	http.ResponseWriter
+}
+
+func (w timeoutWriter) WriteHeader(status int) {
+	if status == http.StatusServiceUnavailable && w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIsLongLived(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/webhooks/stream", true},
+		{"/ws", true},
+		{"/webhooks/export", true},
+		{"/webhooks/export?follow=true", true},
+		{"/webhooks/export.csv", true},
+		{"/webhooks", false},
+		{"/webhook", false},
+	}
+	for _, tt := range tests {
+		if got := isLongLived(httptest.NewRequest(http.MethodGet, tt.path, nil)); got != tt.want {
+			t.Errorf("isLongLived(%s) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
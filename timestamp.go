@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+)
+
+// Check the payload's "timestamp" field for -require-timestamp: it must be
+// a positive whole number of Unix seconds or milliseconds and, when
+// maxSkew > 0, within maxSkew of now
+func checkTimestamp(payload interface{}, now time.Time, maxSkew time.Duration) error {
+	fields, ok := payload.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("payload must be a JSON object with a timestamp field")
+	}
+	value, ok := fields["timestamp"]
+	if !ok {
+		return fmt.Errorf("missing timestamp field")
+	}
+	f, ok := value.(float64)
+	if !ok {
+		return fmt.Errorf("timestamp must be a number, got %T", value)
+	}
+	if f <= 0 || f != math.Trunc(f) || f > math.MaxInt64 {
+		return fmt.Errorf("timestamp must be a positive whole number of Unix seconds or milliseconds, got %v", value)
+	}
+
+	if maxSkew > 0 {
+		skew := now.Sub(unixTimestamp(int64(f)))
+		if skew < -maxSkew || skew > maxSkew {
+			return fmt.Errorf("timestamp is %s away from server time, more than the allowed %s", skew.Abs().Round(time.Second), maxSkew)
+		}
+	}
+	return nil
+}
+
+// Unix seconds, or milliseconds when ts is too large to be seconds
+func unixTimestamp(ts int64) time.Time {
+	if ts >= 1e12 {
+		return time.UnixMilli(ts).UTC()
+	}
+	return time.Unix(ts, 0).UTC()
+}
+
+// Reject the request with 422 unless every payload passes checkTimestamp.
+// Does nothing without -require-timestamp.
+func (s *server) requireTimestamps(w http.ResponseWriter, r *http.Request, payloads ...interface{}) bool {
+	i, err := s.badTimestamp(payloads...)
+	if err == nil {
+		return true
+	}
+	message := "Invalid timestamp: " + err.Error()
+	if len(payloads) > 1 {
+		message = fmt.Sprintf("Invalid timestamp in element %d: %s", i, err)
+	}
+	s.reject(w, r, rejectTimestamp, message, http.StatusUnprocessableEntity)
+	return false
+}
+
+// Index and error of the first payload failing checkTimestamp against the
+// server clock. Nil error without -require-timestamp.
+func (s *server) badTimestamp(payloads ...interface{}) (int, error) {
+	if !s.cfg.requireTimestamp {
+		return 0, nil
+	}
+	now := s.now()
+	for i, payload := range payloads {
+		if err := checkTimestamp(payload, now, s.cfg.timestampSkew); err != nil {
+			return i, err
+		}
+	}
+	return 0, nil
+}
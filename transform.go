@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// Parse a -transform-template value: template text, or the contents of a
+// file when it starts with @
+func loadTransformTemplate(v string) (*template.Template, error) {
+	if v == "" {
+		return nil, nil
+	}
+	text := v
+	if path, ok := strings.CutPrefix(v, "@"); ok {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading transform template: %w", err)
+		}
+		text = string(data)
+	}
+	tmpl, err := template.New("transform").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing transform template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// Render the -transform-template with payload as its data. Failures are
+// logged and give "", so a bad template never costs a webhook.
+func (s *server) transform(payload interface{}) string {
+	if s.cfg.transformTemplate == nil {
+		return ""
+	}
+	var out strings.Builder
+	if err := s.cfg.transformTemplate.Execute(&out, payload); err != nil {
+		slog.Warn("transform template failed", "error", err)
+		return ""
+	}
+	return out.String()
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Remove webhooks received before cutoff, keeping the survivors in order.
+// Returns the number removed.
+func (ws *WebhookStore) RemoveOlderThan(cutoff time.Time) int {
+	ws.mu.Lock()
+	defer ws.unlock()
+
+	expired := ws.webhooks.Filter(func(webhook StoredWebhook) bool {
+		return !webhook.Received.Before(cutoff)
+	})
+	for _, webhook := range expired {
+		ws.forgetLocked(webhook)
+	}
+	ws.evictedLocked(evictTTL, expired...)
+	removed := len(expired)
+
+	if removed > 0 {
+		if err := ws.saveLocked(); err != nil {
+			slog.Error("failed to persist webhooks", "error", err)
+		}
+	}
+	return removed
+}
+
+// How often the sweeper runs: half the TTL, kept between one second and
+// one minute
+func sweepInterval(ttl time.Duration) time.Duration {
+	interval := ttl / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+	return interval
+}
+
+// Periodically expire webhooks older than ttl in every bucket until ctx is
+// cancelled
+func (s *server) sweepExpired(ctx context.Context, ttl time.Duration) {
+	ticker := time.NewTicker(sweepInterval(ttl))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			cutoff := now.Add(-ttl)
+			for _, name := range s.buckets.Names() {
+				store, _ := s.buckets.Lookup(name)
+				exp, ok := store.(expiringStore)
+				if !ok {
+					continue
+				}
+				if removed := exp.RemoveOlderThan(cutoff); removed > 0 {
+					slog.Info("expired webhooks", "bucket", name, "count", removed)
+				}
+			}
+		}
+	}
+}
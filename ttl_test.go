@@ -0,0 +1,46 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRemoveOlderThan(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	start := clock.now
+	ws := NewWebhookStore(10, 0, modeLIFO)
+	ws.now = clock.Now
+	var evicted []int
+	ws.onEvict = func(reason string, webhooks ...StoredWebhook) {
+		for _, webhook := range webhooks {
+			if reason != evictTTL {
+				t.Errorf("webhook %d evicted for %q, want %q", webhook.ID, reason, evictTTL)
+			}
+			evicted = append(evicted, webhook.ID)
+		}
+	}
+
+	for i := 0; i < 4; i++ {
+		ws.Add(StoredWebhook{})
+		clock.Advance(time.Hour)
+	}
+	// Received at start, +1h, +2h and +3h; a cutoff equal to a Received
+	// time keeps that webhook
+	if removed := ws.RemoveOlderThan(start.Add(2 * time.Hour)); removed != 2 {
+		t.Errorf("removed %d, want 2", removed)
+	}
+	if !slices.Equal(evicted, []int{1, 2}) {
+		t.Errorf("evicted %v, want [1 2]", evicted)
+	}
+	var ids []int
+	for _, webhook := range ws.GetAll() {
+		ids = append(ids, webhook.ID)
+	}
+	if !slices.Equal(ids, []int{4, 3}) {
+		t.Errorf("remaining ids %v, want [4 3]", ids)
+	}
+	if removed := ws.RemoveOlderThan(start); removed != 0 {
+		t.Errorf("second sweep removed %d, want 0", removed)
+	}
+}
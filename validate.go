@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Result of a dry run through the webhook pipeline
+type validationResult struct {
+	Valid          bool          `json:"valid"`
+	Bucket         string        `json:"bucket"`
+	Verified       bool          `json:"verified"`
+	SignatureError string        `json:"signature_error,omitempty"`
+	DecodeError    string        `json:"decode_error,omitempty"`
+	SchemaErrors   []schemaError `json:"schema_errors,omitempty"`
+	Payload        interface{}   `json:"payload"`
+	Event          string        `json:"event,omitempty"`
+	DeliveryID     string        `json:"delivery_id,omitempty"`
+	Timestamp      int64         `json:"timestamp,omitempty"`
+	TimestampError string        `json:"timestamp_error,omitempty"`
+	RejectRule     string        `json:"reject_rule,omitempty"`
+}
+
+// POST /webhook/validate[?bucket=<name>]
+//
+// Runs a webhook through the same decoding, signature, -reject-when, schema
+// and timestamp checks as /webhook and reports the outcome, without storing
+// or forwarding it. Valid is false exactly when /webhook would refuse it. The
+// bucket selects which verifier applies and defaults to the default bucket.
+func (s *server) validateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	bucket := r.URL.Query().Get("bucket")
+	if bucket == "" {
+		bucket = defaultBucket
+	} else if !validBucketName(bucket) {
+		writeError(w, "Invalid bucket name", http.StatusBadRequest)
+		return
+	}
+
+	if !s.checkContentType(w, r) {
+		return
+	}
+
+	body, ok := s.readBody(w, r)
+	if !ok {
+		return
+	}
+
+	// ?bucket= is ours, not part of the webhook
+	query := r.URL.Query()
+	query.Del("bucket")
+	if emptyRequest(body, query.Encode()) {
+		writeValidation(w, validationResult{Bucket: bucket, DecodeError: "empty body"})
+		return
+	}
+
+	result := validationResult{Valid: true, Bucket: bucket}
+	if v := s.verifierFor(bucket); v != nil {
+		if err := v.Verify(r.Header, body); err != nil {
+			result.Valid = false
+			result.SignatureError = err.Error()
+		} else {
+			result.Verified = true
+		}
+	}
+
+	payload, err := decodePayload(r.Header.Get("Content-Type"), body, s.maxElements())
+	if err != nil {
+		// Otherwise not a failure: /webhook stores such bodies raw
+		if errors.Is(err, errTooManyElements) {
+			result.Valid = false
+			err = fmt.Errorf("batch exceeds %d elements", s.cfg.maxBatch)
+		}
+		result.DecodeError = err.Error()
+		payload = nil
+	}
+	result.Payload = payload
+
+	payloads := []interface{}{payload}
+	elements, isBatch := payload.([]interface{})
+	if isBatch && s.cfg.expandArrays {
+		payloads = elements
+	}
+
+	if rule, ok := s.matchingRule(payloads...); ok {
+		result.Valid = false
+		result.RejectRule = rule.String()
+	}
+
+	if s.schema != nil {
+		if isBatch && s.cfg.expandArrays {
+			result.SchemaErrors = validateElements(s.schema, elements)
+		} else {
+			result.SchemaErrors = validatePayload(s.schema, payload)
+		}
+		if result.SchemaErrors != nil {
+			result.Valid = false
+		}
+	}
+
+	result.Event, result.DeliveryID = extractMetadata(r.Header, payload)
+	result.Timestamp = getInt64FromPayload(payload, "timestamp")
+	if i, err := s.badTimestamp(payloads...); err != nil {
+		result.Valid = false
+		result.TimestampError = err.Error()
+		if len(payloads) > 1 {
+			result.TimestampError = fmt.Sprintf("element %d: %s", i, err)
+		}
+	}
+
+	writeValidation(w, result)
+}
+
+func writeValidation(w http.ResponseWriter, result validationResult) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
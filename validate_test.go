@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// POST /webhook/validate must call a request valid exactly when /webhook
+// would store it
+func TestValidateMatchesPipeline(t *testing.T) {
+	signed := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name       string
+		args       []string
+		body       string
+		now        time.Time
+		wantValid  bool
+		wantStatus int // from /webhook
+	}{
+		{"plain object", nil, `{"n":1}`, time.Now(), true, http.StatusOK},
+		{"empty body", nil, ``, time.Now(), false, http.StatusBadRequest},
+		{"undecodable body is stored raw", nil, `{"n":`, time.Now(), true, http.StatusOK},
+		{"batch over -max-batch", []string{"-expand-arrays", "-max-batch", "2"}, `[{},{},{}]`, time.Now(), false, http.StatusRequestEntityTooLarge},
+		{"matches -reject-when", []string{"-reject-when", "type=ping"}, `{"type":"ping"}`, time.Now(), false, http.StatusUnprocessableEntity},
+		{"batch element matches -reject-when", []string{"-expand-arrays", "-reject-when", "type=ping"}, `[{"type":"push"},{"type":"ping"}]`, time.Now(), false, http.StatusUnprocessableEntity},
+		{"no rule match", []string{"-reject-when", "type=ping"}, `{"type":"push"}`, time.Now(), true, http.StatusOK},
+		{"timestamp within skew", []string{"-require-timestamp", "-timestamp-skew", "1m"}, `{"timestamp":1700000000}`, signed.Add(30 * time.Second), true, http.StatusOK},
+		{"timestamp outside skew", []string{"-require-timestamp", "-timestamp-skew", "1m"}, `{"timestamp":1700000000}`, signed.Add(2 * time.Minute), false, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t, tt.args...)
+			srv.now = func() time.Time { return tt.now }
+
+			rec := postJSON(srv, "/webhook/validate", tt.body)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("validate: status %d, body %s", rec.Code, rec.Body)
+			}
+			var result validationResult
+			decodeResponse(t, rec, &result)
+			if result.Valid != tt.wantValid {
+				t.Errorf("validate: valid = %v, want %v (%s)", result.Valid, tt.wantValid, rec.Body)
+			}
+			if srv.store.Len() != 0 {
+				t.Fatal("validate stored a webhook")
+			}
+
+			if rec := postJSON(srv, "/webhook", tt.body); rec.Code != tt.wantStatus {
+				t.Errorf("/webhook: status %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
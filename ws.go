@@ -0,0 +1,174 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsWriteWait  = 10 * time.Second
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = wsPongWait * 9 / 10
+	wsReadLimit  = 4096
+)
+
+// Frame sent to WebSocket clients. Type is one of:
+//
+//	snapshot  webhooks holds the current stack, in list order
+//	webhook   webhook holds a newly stored webhook
+//	cleared   removed holds the number of webhooks removed by a clear command
+//	error     error describes a command that couldn't be handled
+type wsMessage struct {
+	Type     string           `json:"type"`
+	Webhooks *[]StoredWebhook `json:"webhooks,omitempty"`
+	Webhook  *StoredWebhook   `json:"webhook,omitempty"`
+	Removed  *int             `json:"removed,omitempty"`
+	Error    string           `json:"error,omitempty"`
+}
+
+// Frame received from WebSocket clients. Cmd is one of:
+//
+//	snapshot  resend the current stack as a snapshot frame
+//	clear     remove every stored webhook, answered with a cleared frame;
+//	          needs X-Admin-Token on the handshake when -admin-token is set
+type wsCommand struct {
+	Cmd string `json:"cmd"`
+}
+
+// GET /ws. Sends a snapshot frame on connect, then a webhook frame for each
+// newly stored webhook, and accepts wsCommand frames from the client.
+func (s *server) wsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sub, ok := s.store.(subscribableStore)
+	if !ok {
+		writeError(w, "Streaming not supported by this store", http.StatusNotImplemented)
+		return
+	}
+
+	// Browsers can't set headers on a WebSocket handshake, so the admin
+	// token for the clear command has to be checked here
+	admin := s.isAdmin(r)
+
+	upgrader := websocket.Upgrader{CheckOrigin: s.wsCheckOrigin}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already sent an error response
+		slog.Debug("websocket upgrade failed", "remote_addr", s.clientIP(r), "error", err)
+		return
+	}
+	defer conn.Close()
+
+	snapshot, updates, cancel := sub.Subscribe()
+	defer cancel()
+
+	commands := make(chan wsCommand)
+	closed := make(chan struct{})
+	done := make(chan struct{})
+	defer close(done)
+	go wsReadCommands(conn, commands, closed, done)
+
+	if err := wsWrite(conn, wsMessage{Type: "snapshot", Webhooks: &snapshot}); err != nil {
+		return
+	}
+
+	ping := time.NewTicker(wsPingPeriod)
+	defer ping.Stop()
+
+	for {
+		var msg wsMessage
+		select {
+		case <-r.Context().Done():
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"),
+				time.Now().Add(wsWriteWait))
+			return
+		case <-closed:
+			return
+		case webhook := <-updates:
+			msg = wsMessage{Type: "webhook", Webhook: &webhook}
+		case cmd := <-commands:
+			msg = s.wsRunCommand(cmd, admin)
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				return
+			}
+			continue
+		}
+		if err := wsWrite(conn, msg); err != nil {
+			return
+		}
+	}
+}
+
+func (s *server) wsRunCommand(cmd wsCommand, admin bool) wsMessage {
+	switch cmd.Cmd {
+	case "snapshot":
+		webhooks := s.store.GetAll()
+		return wsMessage{Type: "snapshot", Webhooks: &webhooks}
+	case "clear":
+		if !admin {
+			return wsMessage{Type: "error", Error: "clear requires the " + adminTokenHeader + " header on the handshake"}
+		}
+		removed := s.store.Clear()
+		slog.Info("webhooks cleared over websocket", "count", removed)
+		return wsMessage{Type: "cleared", Removed: &removed}
+	default:
+		return wsMessage{Type: "error", Error: "unknown command " + cmd.Cmd}
+	}
+}
+
+// Read client frames until the connection fails or done is closed, passing
+// commands to the writer. Gorilla allows one reader and one writer at a
+// time, so all writes stay in wsHandler.
+func wsReadCommands(conn *websocket.Conn, commands chan<- wsCommand, closed chan<- struct{}, done <-chan struct{}) {
+	defer close(closed)
+
+	conn.SetReadLimit(wsReadLimit)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		var cmd wsCommand
+		if err := conn.ReadJSON(&cmd); err != nil {
+			if _, ok := err.(*websocket.CloseError); !ok {
+				slog.Debug("websocket read failed", "error", err)
+			}
+			return
+		}
+		select {
+		case commands <- cmd:
+		case <-done:
+			return
+		}
+	}
+}
+
+func wsWrite(conn *websocket.Conn, msg wsMessage) error {
+	conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return conn.WriteJSON(msg)
+}
+
+// Accept same-origin browsers, non-browser clients and the -cors-origin
+// allowlist
+func (s *server) wsCheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if slices.Contains(s.cfg.corsOrigins, "*") || slices.Contains(s.cfg.corsOrigins, origin) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}